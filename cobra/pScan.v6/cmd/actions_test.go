@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"cobra/pScan.v6/scan"
 )
@@ -63,7 +64,7 @@ func TestScanAction(t *testing.T) {
 	var out bytes.Buffer
 
 	// Execute scan and capture output
-	if err := scanAction(&out, tf, ports); err != nil {
+	if err := scanAction(&out, tf, ports, scan.Options{}); err != nil {
 		t.Fatalf("expected no error, got %q\n", err)
 	}
 
@@ -71,6 +72,85 @@ func TestScanAction(t *testing.T) {
 	if out.String() != expectedOut {
 		t.Errorf("expected output %q, got %q\n", expectedOut, out.String())
 	}
+
+	// Line formats of UDP ports and of the services found from banners
+	testCases := []struct {
+		name    string
+		udp     bool
+		banner  string
+		expLine string
+	}{
+		{name: "udp", udp: true, expLine: "\t%d/udp: open\n"},
+		{name: "serviceVersion", banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3\r\n", expLine: "\t%d: open ssh (OpenSSH_9.6p1)\n"},
+		{name: "service", banner: "HTTP/1.0 200 OK\r\n\r\n", expLine: "\t%d: open http\n"},
+		{name: "unknownBanner", banner: "hello pScan\r\n", expLine: "\t%d: open \"hello pScan\"\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := "127.0.0.1"
+
+			tf, cleanup := setup(t, []string{host}, true)
+			defer cleanup()
+
+			var port int
+
+			if tc.udp {
+				conn, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Close()
+
+				port = conn.LocalAddr().(*net.UDPAddr).Port
+
+				go func() {
+					buf := make([]byte, 512)
+
+					_, addr, err := conn.ReadFrom(buf)
+					if err != nil {
+						return
+					}
+
+					conn.WriteTo([]byte("pong"), addr)
+				}()
+			} else {
+				ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer ln.Close()
+
+				port = ln.Addr().(*net.TCPAddr).Port
+
+				go func() {
+					c, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					defer c.Close()
+
+					c.Write([]byte(tc.banner))
+				}()
+			}
+
+			opts := scan.Options{UDP: tc.udp, Banner: !tc.udp, Timeout: 300 * time.Millisecond}
+
+			expectedOut := fmt.Sprintln(host)
+			expectedOut += fmt.Sprintf(tc.expLine, port)
+			expectedOut += fmt.Sprintln()
+
+			var out bytes.Buffer
+
+			if err := scanAction(&out, tf, []int{port}, opts); err != nil {
+				t.Fatalf("expected no error, got %q\n", err)
+			}
+
+			if out.String() != expectedOut {
+				t.Errorf("expected output %q, got %q\n", expectedOut, out.String())
+			}
+		})
+	}
 }
 
 func setup(t *testing.T, hosts []string, initList bool) (string, func()) {
@@ -224,7 +304,7 @@ func TestIntegration(t *testing.T) {
 	}
 
 	// scan hosts
-	if err := scanAction(&out, tf, nil); err != nil {
+	if err := scanAction(&out, tf, nil, scan.Options{}); err != nil {
 		t.Fatalf("expected output no error, got %q\n", err)
 	}
 
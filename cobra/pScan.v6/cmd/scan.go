@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"cobra/pScan.v6/scan"
 
@@ -23,23 +24,61 @@ var scanCmd = &cobra.Command{
 			return err
 		}
 
-		ports, err := cmd.Flags().GetIntSlice("ports")
+		portSpecs, err := cmd.Flags().GetStringSlice("ports")
 		if err != nil {
 			return err
 		}
 
-		return scanAction(os.Stdout, hostsFile, ports)
+		ports, err := scan.ParsePorts(portSpecs...)
+		if err != nil {
+			return err
+		}
+
+		workers, err := cmd.Flags().GetInt("workers")
+		if err != nil {
+			return err
+		}
+
+		hostWorkers, err := cmd.Flags().GetInt("host-workers")
+		if err != nil {
+			return err
+		}
+
+		udp, err := cmd.Flags().GetBool("udp")
+		if err != nil {
+			return err
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+
+		banner, err := cmd.Flags().GetBool("banner")
+		if err != nil {
+			return err
+		}
+
+		opts := scan.Options{
+			Workers:     workers,
+			HostWorkers: hostWorkers,
+			Timeout:     timeout,
+			UDP:         udp,
+			Banner:      banner,
+		}
+
+		return scanAction(os.Stdout, hostsFile, ports, opts)
 	},
 }
 
-func scanAction(out io.Writer, hostsFile string, ports []int) error {
+func scanAction(out io.Writer, hostsFile string, ports []int, opts scan.Options) error {
 	hl := &scan.HostsList{}
 
 	if err := hl.Load(hostsFile); err != nil {
 		return err
 	}
 
-	results := scan.Run(hl, ports)
+	results := scan.RunOptions(hl, ports, opts)
 
 	return printResults(out, results)
 }
@@ -58,7 +97,7 @@ func printResults(out io.Writer, results []scan.Results) error {
 		message += fmt.Sprintln()
 
 		for _, p := range r.PortState {
-			message += fmt.Sprintf("\t%d: %s\n", p.Port, p.Open)
+			message += portLine(p)
 		}
 
 		message += fmt.Sprintln()
@@ -69,8 +108,45 @@ func printResults(out io.Writer, results []scan.Results) error {
 
 }
 
+// portLine formats the state of a port along with its service: the one
+// identified from its banner or else the well known service for the port.
+// Banners not identifying a service are shown as they are
+func portLine(p scan.PortState) string {
+	line := fmt.Sprintf("\t%d", p.Port)
+	if p.Protocol == "udp" {
+		line += "/udp"
+	}
+
+	line += fmt.Sprintf(": %s", p.State)
+
+	service := p.Service
+	if service == "" {
+		service = scan.ServiceName(p.Port)
+	}
+
+	if service != "" {
+		line += " " + service
+	}
+
+	if p.Version != "" {
+		line += fmt.Sprintf(" (%s)", p.Version)
+	}
+
+	if p.Service == "" && p.Banner != "" {
+		line += fmt.Sprintf(" %q", p.Banner)
+	}
+
+	return line + "\n"
+}
+
 func init() {
 	rootCmd.AddCommand(scanCmd)
 
-	scanCmd.Flags().IntSliceP("ports", "p", []int{22, 80, 443}, "ports to scan")
+	scanCmd.Flags().StringSliceP("ports", "p", []string{"22", "80", "443"},
+		"ports to scan: numbers, ranges like 1-1024 or sets ("+strings.Join(scan.PortSets(), ", ")+")")
+	scanCmd.Flags().IntP("workers", "w", scan.DefaultWorkers, "number of ports to scan concurrently")
+	scanCmd.Flags().Int("host-workers", 0, "number of ports to scan concurrently on a single host (0 for no limit)")
+	scanCmd.Flags().Bool("udp", false, "scan UDP ports instead of TCP ports")
+	scanCmd.Flags().Duration("timeout", scan.DefaultTimeout, "how long to wait for each port to answer")
+	scanCmd.Flags().Bool("banner", false, "read banners from open TCP ports to identify their services")
 }
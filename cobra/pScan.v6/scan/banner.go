@@ -0,0 +1,152 @@
+package scan
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxBanner is the longest banner kept on a PortState
+const maxBanner = 80
+
+var (
+	httpProbe     = []byte("HEAD / HTTP/1.0\r\n\r\n")
+	redisProbe    = []byte("INFO server\r\n")
+	postgresProbe = []byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f} // SSLRequest
+)
+
+// tcpProbe returns the payload sent to services that wait for the client
+// to speak first, and whether the service on port is known to do so. The
+// HTTP probe is sent to ports not sending a banner on their own
+func tcpProbe(port int) ([]byte, bool) {
+	switch port {
+	case 6379:
+		return redisProbe, true
+	case 5432:
+		return postgresProbe, true
+	}
+
+	if set, _ := portSet("web"); contains(set, port) {
+		return httpProbe, true
+	}
+
+	return httpProbe, false
+}
+
+func contains(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceMatcher identifies a service from its response. The first
+// submatch of version, if any, is the service version
+type serviceMatcher struct {
+	service string
+	match   *regexp.Regexp
+	version *regexp.Regexp
+}
+
+var serviceMatchers = []serviceMatcher{
+	{"ssh", regexp.MustCompile(`^SSH-\d+\.\d+-`), regexp.MustCompile(`^SSH-\d+\.\d+-(\S+)`)},
+	{"smtp", regexp.MustCompile(`^220[ -].*SMTP`), regexp.MustCompile(`^220[ -]\S+ E?SMTP ([^\r\n]+)`)},
+	{"http", regexp.MustCompile(`^HTTP/\d(\.\d)? \d{3}`), regexp.MustCompile(`(?im)^Server: *([^\r\n]+)`)},
+	{"redis", regexp.MustCompile(`^(\$\d+\r\n# Server|\+PONG|-ERR |-NOAUTH |-DENIED )`), regexp.MustCompile(`redis_version:(\S+)`)},
+	{"postgresql", regexp.MustCompile(`^([SN]$|E\x00\x00.*SFATAL)`), nil},
+}
+
+// IdentifyService returns the service, and its version when known,
+// answering with response. It returns empty strings for unknown services
+func IdentifyService(response string) (service, version string) {
+	for _, m := range serviceMatchers {
+		if !m.match.MatchString(response) {
+			continue
+		}
+
+		if m.version != nil {
+			if v := m.version.FindStringSubmatch(response); v != nil {
+				version = strings.TrimSpace(v[1])
+			}
+		}
+
+		return m.service, version
+	}
+
+	return "", ""
+}
+
+// grabBanner reads the banner a service sends on conn, sending it a probe
+// first when it waits for the client, or when it does not send anything
+// within timeout. It records the service found on p
+func grabBanner(conn net.Conn, p *PortState, timeout time.Duration) {
+	buf := make([]byte, 4096)
+
+	probe, clientFirst := tcpProbe(p.Port)
+
+	n := 0
+	if !clientFirst {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		n, _ = conn.Read(buf)
+	}
+
+	if n == 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+
+		if _, err := conn.Write(probe); err != nil {
+			return
+		}
+
+		n = readAll(conn, buf)
+	}
+
+	if n == 0 {
+		return
+	}
+
+	response := string(buf[:n])
+
+	p.Banner = cleanBanner(response)
+	p.Service, p.Version = IdentifyService(response)
+}
+
+// readAll reads into buf until it is full, the connection is closed or
+// its deadline expires, returning the number of bytes read
+func readAll(conn net.Conn, buf []byte) int {
+	n := 0
+
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		n += m
+
+		if err != nil {
+			break
+		}
+	}
+
+	return n
+}
+
+// cleanBanner returns the first line of response without unprintable
+// characters, truncated to maxBanner characters
+func cleanBanner(response string) string {
+	line, _, _ := strings.Cut(response, "\n")
+
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, line)
+
+	if r := []rune(line); len(r) > maxBanner {
+		line = string(r[:maxBanner])
+	}
+
+	return line
+}
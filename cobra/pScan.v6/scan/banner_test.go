@@ -0,0 +1,131 @@
+package scan_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"cobra/pScan.v6/scan"
+)
+
+func TestIdentifyService(t *testing.T) {
+	testCases := []struct {
+		name          string
+		response      string
+		expectService string
+		expectVersion string
+	}{
+		{"SSH", "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n", "ssh", "OpenSSH_9.6p1"},
+		{"SMTP", "220 mail.example.com ESMTP Postfix (Ubuntu)\r\n", "smtp", "Postfix (Ubuntu)"},
+		{"HTTP", "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nServer: nginx/1.25.3\r\n\r\n", "http", "nginx/1.25.3"},
+		{"HTTPNoServer", "HTTP/1.0 404 Not Found\r\n\r\n", "http", ""},
+		{"RedisInfo", "$1024\r\n# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n", "redis", "7.2.4"},
+		{"RedisError", "-ERR unknown command 'HEAD'\r\n", "redis", ""},
+		{"PostgresSSL", "N", "postgresql", ""},
+		{"Unknown", "hello\r\n", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, version := scan.IdentifyService(tc.response)
+
+			if service != tc.expectService {
+				t.Errorf("expected service %q, got %q instead\n", tc.expectService, service)
+			}
+
+			if version != tc.expectVersion {
+				t.Errorf("expected version %q, got %q instead\n", tc.expectVersion, version)
+			}
+		})
+	}
+}
+
+func TestRunBanner(t *testing.T) {
+	testCases := []struct {
+		name          string
+		serve         func(net.Conn)
+		expectBanner  string
+		expectService string
+		expectVersion string
+	}{
+		{
+			name: "ServerFirst",
+			serve: func(c net.Conn) {
+				c.Write([]byte("SSH-2.0-OpenSSH_9.6p1 Ubuntu-3\r\n"))
+			},
+			expectBanner:  "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3",
+			expectService: "ssh",
+			expectVersion: "OpenSSH_9.6p1",
+		},
+		{
+			name: "ClientFirst",
+			serve: func(c net.Conn) {
+				r := bufio.NewReader(c)
+				if line, err := r.ReadString('\n'); err != nil || line != "HEAD / HTTP/1.0\r\n" {
+					return
+				}
+				c.Write([]byte("HTTP/1.0 200 OK\r\nServer: pScanTest/1.0\r\n\r\n"))
+			},
+			expectBanner:  "HTTP/1.0 200 OK",
+			expectService: "http",
+			expectVersion: "pScanTest/1.0",
+		},
+		{
+			name:  "Silent",
+			serve: func(c net.Conn) {},
+		},
+	}
+
+	host := "127.0.0.1"
+
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	ports := []int{}
+
+	for _, tc := range testCases {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer ln.Close()
+
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+
+		go func(serve func(net.Conn)) {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+
+				serve(c)
+				c.Close()
+			}
+		}(tc.serve)
+	}
+
+	res := scan.RunOptions(hl, ports, scan.Options{Banner: true, Timeout: 300 * time.Millisecond})
+
+	if len(res) != 1 || len(res[0].PortState) != len(ports) {
+		t.Fatalf("expected 1 result with %d port states, got %v instead\n", len(ports), res)
+	}
+
+	for i, tc := range testCases {
+		ps := res[0].PortState[i]
+
+		if ps.State != scan.Open {
+			t.Errorf("%s: expected port %d to be open, got %s instead\n", tc.name, ps.Port, ps.State)
+		}
+
+		if ps.Banner != tc.expectBanner {
+			t.Errorf("%s: expected banner %q, got %q instead\n", tc.name, tc.expectBanner, ps.Banner)
+		}
+
+		if ps.Service != tc.expectService || ps.Version != tc.expectVersion {
+			t.Errorf("%s: expected %q %q, got %q %q instead\n", tc.name, tc.expectService, tc.expectVersion, ps.Service, ps.Version)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	MinPort = 1
+	MaxPort = 65535
+)
+
+var (
+	ErrInvalidPort    = errors.New("invalid port")
+	ErrInvalidRange   = errors.New("invalid port range")
+	ErrUnknownPortSet = errors.New("unknown port set")
+)
+
+// service is a well known service listening on port. Services in a set
+// are scanned together by naming the set
+type service struct {
+	port int
+	name string
+	set  string
+}
+
+// services is the built-in service table
+var services = []service{
+	{21, "ftp", ""},
+	{22, "ssh", ""},
+	{23, "telnet", ""},
+	{25, "smtp", "mail"},
+	{53, "domain", ""},
+	{80, "http", "web"},
+	{110, "pop3", "mail"},
+	{123, "ntp", ""},
+	{143, "imap", "mail"},
+	{161, "snmp", ""},
+	{443, "https", "web"},
+	{445, "microsoft-ds", ""},
+	{465, "smtps", "mail"},
+	{587, "submission", "mail"},
+	{993, "imaps", "mail"},
+	{995, "pop3s", "mail"},
+	{1433, "ms-sql", "db"},
+	{1521, "oracle", "db"},
+	{3306, "mysql", "db"},
+	{3389, "rdp", ""},
+	{5432, "postgresql", "db"},
+	{5900, "vnc", ""},
+	{6379, "redis", "db"},
+	{8000, "http-alt", "web"},
+	{8008, "http-alt", "web"},
+	{8080, "http-proxy", "web"},
+	{8443, "https-alt", "web"},
+	{8888, "http-alt", "web"},
+	{9200, "elasticsearch", "db"},
+	{11211, "memcached", "db"},
+	{27017, "mongodb", "db"},
+}
+
+// top100 lists the 100 most commonly open TCP ports
+var top100 = []int{
+	7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111,
+	113, 119, 135, 139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465,
+	513, 514, 515, 543, 544, 548, 554, 587, 631, 646, 873, 990, 993, 995,
+	1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900,
+	2000, 2001, 2049, 2121, 2717, 3000, 3128, 3306, 3389, 3986, 4899,
+	5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800,
+	5900, 6000, 6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443,
+	8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156,
+	49157,
+}
+
+// PortSets returns the sorted names of the port sets
+func PortSets() []string {
+	names := []string{"top100"}
+
+	seen := map[string]bool{}
+	for _, s := range services {
+		if s.set != "" && !seen[s.set] {
+			seen[s.set] = true
+			names = append(names, s.set)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// portSet returns the ports of the named set
+func portSet(name string) ([]int, bool) {
+	if name == "top100" {
+		return top100, true
+	}
+
+	var ports []int
+	for _, s := range services {
+		if s.set == name {
+			ports = append(ports, s.port)
+		}
+	}
+
+	return ports, len(ports) > 0
+}
+
+// ServiceName returns the name of the well known service listening on
+// port, or an empty string
+func ServiceName(port int) string {
+	for _, s := range services {
+		if s.port == port {
+			return s.name
+		}
+	}
+
+	return ""
+}
+
+// ParsePorts parses port specifications into a list of ports. Each
+// specification is a comma separated list of ports, ranges such as
+// 1-1024 and names of port sets such as web. Ports keep the order they
+// are given in, without duplicates
+func ParsePorts(specs ...string) ([]int, error) {
+	ports := []int{}
+	seen := map[int]bool{}
+
+	add := func(p int) {
+		if !seen[p] {
+			seen[p] = true
+			ports = append(ports, p)
+		}
+	}
+
+	for _, spec := range specs {
+		for _, item := range strings.Split(spec, ",") {
+			item = strings.TrimSpace(item)
+
+			if item == "" {
+				continue
+			}
+
+			if set, ok := portSet(strings.ToLower(item)); ok {
+				for _, p := range set {
+					add(p)
+				}
+				continue
+			}
+
+			if unicode.IsLetter(rune(item[0])) {
+				return nil, fmt.Errorf("%w: %q: known sets are %s", ErrUnknownPortSet, item, strings.Join(PortSets(), ", "))
+			}
+
+			lo, hi, isRange := strings.Cut(item, "-")
+			if !isRange {
+				hi = lo
+			}
+
+			first, err := parsePort(lo)
+			if err != nil {
+				return nil, err
+			}
+
+			last, err := parsePort(hi)
+			if err != nil {
+				return nil, err
+			}
+
+			if first > last {
+				return nil, fmt.Errorf("%w: %s: start greater than end", ErrInvalidRange, item)
+			}
+
+			for p := first; p <= last; p++ {
+				add(p)
+			}
+		}
+	}
+
+	return ports, nil
+}
+
+// parsePort parses a single port number, checking it is within range
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPort, s)
+	}
+
+	if p < MinPort || p > MaxPort {
+		return 0, fmt.Errorf("%w: %d: must be between %d and %d", ErrInvalidPort, p, MinPort, MaxPort)
+	}
+
+	return p, nil
+}
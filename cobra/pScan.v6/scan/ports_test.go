@@ -0,0 +1,76 @@
+package scan_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cobra/pScan.v6/scan"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		name      string
+		specs     []string
+		expPorts  []int
+		expLen    int
+		expectErr error
+	}{
+		{name: "Single", specs: []string{"22"}, expPorts: []int{22}},
+		{name: "List", specs: []string{"22", "80", "443"}, expPorts: []int{22, 80, 443}},
+		{name: "Range", specs: []string{"8000-8003"}, expPorts: []int{8000, 8001, 8002, 8003}},
+		{name: "Mixed", specs: []string{"22,80,8000-8002"}, expPorts: []int{22, 80, 8000, 8001, 8002}},
+		{name: "Duplicates", specs: []string{"80,79-81", "80"}, expPorts: []int{80, 79, 81}},
+		{name: "WebSet", specs: []string{"web"}, expPorts: []int{80, 443, 8000, 8008, 8080, 8443, 8888}},
+		{name: "SetAndPorts", specs: []string{"22,DB"}, expPorts: []int{22, 1433, 1521, 3306, 5432, 6379, 9200, 11211, 27017}},
+		{name: "Top100", specs: []string{"top100"}, expLen: 100},
+		{name: "FullRange", specs: []string{"1-65535"}, expLen: 65535},
+		{name: "Zero", specs: []string{"0"}, expectErr: scan.ErrInvalidPort},
+		{name: "TooHigh", specs: []string{"22,65536"}, expectErr: scan.ErrInvalidPort},
+		{name: "RangeTooHigh", specs: []string{"65000-70000"}, expectErr: scan.ErrInvalidPort},
+		{name: "Negative", specs: []string{"-1"}, expectErr: scan.ErrInvalidPort},
+		{name: "ReversedRange", specs: []string{"1024-1"}, expectErr: scan.ErrInvalidRange},
+		{name: "UnknownSet", specs: []string{"games"}, expectErr: scan.ErrUnknownPortSet},
+		{name: "NotANumber", specs: []string{"8o"}, expectErr: scan.ErrInvalidPort},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.ParsePorts(tc.specs...)
+
+			if tc.expectErr != nil {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil instead\n", tc.expectErr)
+				}
+
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("expected error %q, got %q instead\n", tc.expectErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q instead\n", err)
+			}
+
+			if tc.expPorts != nil && !reflect.DeepEqual(ports, tc.expPorts) {
+				t.Errorf("expected ports %v, got %v instead\n", tc.expPorts, ports)
+			}
+
+			if tc.expLen != 0 && len(ports) != tc.expLen {
+				t.Errorf("expected %d ports, got %d instead\n", tc.expLen, len(ports))
+			}
+		})
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	if name := scan.ServiceName(22); name != "ssh" {
+		t.Errorf("expected %q, got %q instead\n", "ssh", name)
+	}
+
+	if name := scan.ServiceName(12345); name != "" {
+		t.Errorf("expected no service, got %q instead\n", name)
+	}
+}
@@ -1,44 +1,110 @@
 package scan
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"syscall"
 	"time"
 )
 
-// Portstate represent the state of a single TCP port
+// Portstate represent the state of a single TCP or UDP port. Banner
+// holds the first line sent by the service on an open TCP port, Service
+// and Version what it was identified as, when banner grabbing is enabled
 type PortState struct {
-	Port int
-	Open state
+	Port     int
+	Protocol string
+	State    state
+	Banner   string `json:",omitempty"`
+	Service  string `json:",omitempty"`
+	Version  string `json:",omitempty"`
 }
 
-type state bool
+type state int
+
+const (
+	// Closed is a port refusing connections
+	Closed state = iota
+	Open
+	// Filtered is a port that did not answer or that a firewall
+	// reported as unreachable
+	Filtered
+	// OpenFiltered is a UDP port that did not answer: either open or
+	// with the probe or its answer dropped by a firewall
+	OpenFiltered
+)
 
-// String converts the boolean value od state to a human readable string
+// String converts the value of state to a human readable string
 func (s state) String() string {
-	if s {
+	switch s {
+	case Open:
 		return "open"
+	case Filtered:
+		return "filtered"
+	case OpenFiltered:
+		return "open|filtered"
 	}
 
 	return "closed"
 }
 
+// MarshalText encodes state as its human readable string in structured
+// output such as JSON
+func (s state) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// dialState derives the state of a port from the error of a connection
+// attempt: a refused or reset connection means closed, a timeout or an
+// unreachable host or network means filtered
+func dialState(err error) state {
+	switch {
+	case err == nil:
+		return Open
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return Closed
+	}
+
+	// Timeouts, unreachable hosts and networks, administratively
+	// prohibited connections and other failures give no evidence of a
+	// listening service
+	return Filtered
+}
+
+// unreachable reports whether err is an ICMP unreachable error other
+// than port unreachable
+func unreachable(err error) bool {
+	return errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM)
+}
+
 // scanport performs a port scan on a single port
-func scanPort(host string, port int) PortState {
+func scanPort(host string, port int, opts Options) PortState {
+	if opts.UDP {
+		return scanUDPPort(host, port, opts.timeout())
+	}
+
 	p := PortState{
-		Port: port,
+		Port:     port,
+		Protocol: "tcp",
 	}
 
 	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
 
-	scanConn, err := net.DialTimeout("tcp", address, 1*time.Second)
+	scanConn, err := net.DialTimeout("tcp", address, opts.timeout())
+
+	p.State = dialState(err)
 
 	if err != nil {
 		return p
 	}
+	defer scanConn.Close()
+
+	if opts.Banner {
+		grabBanner(scanConn, &p, opts.timeout())
+	}
 
-	scanConn.Close()
-	p.Open = true
 	return p
 }
 
@@ -49,28 +115,123 @@ type Results struct {
 	PortState []PortState
 }
 
+// DefaultWorkers is the number of ports scanned at the same time when
+// Options does not set it
+const DefaultWorkers = 100
+
+// DefaultTimeout is how long to wait for a port to answer when Options
+// does not set it
+const DefaultTimeout = 1 * time.Second
+
+// Options configures a scan. Workers bounds the number of ports scanned
+// at the same time across all hosts, HostWorkers the number scanned at
+// the same time on a single host. Zero values select DefaultWorkers and
+// no per host limit. UDP scans UDP ports instead of TCP ones. Banner
+// reads the banner of the services on open TCP ports to identify them
+type Options struct {
+	Workers     int
+	HostWorkers int
+	Timeout     time.Duration
+	UDP         bool
+	Banner      bool
+}
+
+func (o Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultTimeout
+	}
+
+	return o.Timeout
+}
+
 // Run performs a prot port scan on the hosts list
 func Run(hl *HostsList, ports []int) []Results {
+	return RunOptions(hl, ports, Options{})
+}
 
-	res := make([]Results, 0, len(hl.Hosts))
+// RunOptions performs a concurrent port scan on the hosts list using a
+// bounded pool of workers. Results keep the order of the hosts list and
+// port states the order of ports
+func RunOptions(hl *HostsList, ports []int, opts Options) []Results {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
 
-	for _, h := range hl.Hosts {
-		r := Results{
-			Host: h,
-		}
+	res := make([]Results, len(hl.Hosts))
+
+	// Look up every host first, so ports are only scanned on hosts
+	// that exist
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i, h := range hl.Hosts {
+		res[i].Host = h
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(r *Results) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if _, err := net.LookupHost(r.Host); err != nil {
+				r.NotFound = true
+			}
+		}(&res[i])
+	}
+
+	wg.Wait()
+
+	type job struct {
+		host, port int
+	}
 
-		if _, err := net.LookupHost(h); err != nil {
-			r.NotFound = true
-			res = append(res, r)
-			continue
+	hostSems := make([]chan struct{}, len(res))
+	for i := range res {
+		if !res[i].NotFound {
+			res[i].PortState = make([]PortState, len(ports))
 		}
 
-		for _, p := range ports {
-			r.PortState = append(r.PortState, scanPort(h, p))
+		if opts.HostWorkers > 0 {
+			hostSems[i] = make(chan struct{}, opts.HostWorkers)
 		}
+	}
+
+	jobs := make(chan job)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				hostSem := hostSems[j.host]
+				if hostSem != nil {
+					hostSem <- struct{}{}
+				}
 
-		res = append(res, r)
+				res[j.host].PortState[j.port] = scanPort(res[j.host].Host, ports[j.port], opts)
+
+				if hostSem != nil {
+					<-hostSem
+				}
+			}
+		}()
 	}
 
+	// Interleave hosts so a per host limit does not hold workers back
+	for p := range ports {
+		for h := range res {
+			if !res[h].NotFound {
+				jobs <- job{host: h, port: p}
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
 	return res
 }
@@ -1,9 +1,11 @@
 package scan_test
 
 import (
+	"encoding/json"
 	"net"
 	"strconv"
 	"testing"
+	"time"
 
 	"cobra/pScan.v6/scan"
 )
@@ -11,13 +13,56 @@ import (
 func TestStateString(t *testing.T) {
 	ps := scan.PortState{}
 
-	if ps.Open.String() != "closed" {
-		t.Errorf("expected %q, got %q instead\n", "closed", ps.Open.String())
+	if ps.State.String() != "closed" {
+		t.Errorf("expected %q, got %q instead\n", "closed", ps.State.String())
 	}
 
-	ps.Open = true
-	if ps.Open.String() != "open" {
-		t.Errorf("expected %q, got %q instead\n", "open", ps.Open.String())
+	ps.State = scan.Open
+	if ps.State.String() != "open" {
+		t.Errorf("expected %q, got %q instead\n", "open", ps.State.String())
+	}
+
+	ps.State = scan.Filtered
+	if ps.State.String() != "filtered" {
+		t.Errorf("expected %q, got %q instead\n", "filtered", ps.State.String())
+	}
+
+	ps.State = scan.OpenFiltered
+	if ps.State.String() != "open|filtered" {
+		t.Errorf("expected %q, got %q instead\n", "open|filtered", ps.State.String())
+	}
+}
+
+func TestStateJSON(t *testing.T) {
+	ps := scan.PortState{Port: 80, Protocol: "tcp", State: scan.Filtered}
+
+	data, err := json.Marshal(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"Port":80,"Protocol":"tcp","State":"filtered"}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s instead\n", expected, data)
+	}
+}
+
+func TestRunFiltered(t *testing.T) {
+	// Packets to the IPv6 discard prefix are dropped: connections time
+	// out, or fail with an unreachable error without IPv6 routes
+	host := "100::1"
+
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	res := scan.RunOptions(hl, []int{80}, scan.Options{Timeout: 200 * time.Millisecond})
+
+	if len(res) != 1 || len(res[0].PortState) != 1 {
+		t.Fatalf("expected 1 result with 1 port state, got %v instead\n", res)
+	}
+
+	if state := res[0].PortState[0].State.String(); state != "filtered" {
+		t.Errorf("expected port 80 to be %s, got %s instead\n", "filtered", state)
 	}
 }
 
@@ -88,7 +133,7 @@ func TestRunHostFound(t *testing.T) {
 			t.Errorf("expected port %d, got %d instead\n", ports[0], res[0].PortState[i].Port)
 		}
 
-		if res[0].PortState[i].Open.String() != tc.expectState {
+		if res[0].PortState[i].State.String() != tc.expectState {
 			t.Errorf("expected port %d to be %s\n", ports[i], tc.expectState)
 		}
 	}
@@ -119,3 +164,73 @@ func TestRunHostNotfound(t *testing.T) {
 		t.Fatalf("expected 0 port state, got %d instead\n", len(res[0].PortState))
 	}
 }
+
+func TestRunOptions(t *testing.T) {
+	hosts := []string{"localhost", "127.0.0.1"}
+
+	hl := &scan.HostsList{}
+	for _, h := range hosts {
+		hl.Add(h)
+	}
+
+	ports := []int{}
+	expectState := map[int]string{}
+
+	// Init ports, even ones open, odd ones closed
+	for i := 0; i < 20; i++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer ln.Close()
+
+		port := ln.Addr().(*net.TCPAddr).Port
+		ports = append(ports, port)
+		expectState[port] = "open"
+
+		if i%2 == 1 {
+			ln.Close()
+			expectState[port] = "closed"
+		}
+	}
+
+	testCases := []struct {
+		name string
+		opts scan.Options
+	}{
+		{"Default", scan.Options{}},
+		{"SingleWorker", scan.Options{Workers: 1}},
+		{"HostLimit", scan.Options{Workers: 8, HostWorkers: 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := scan.RunOptions(hl, ports, tc.opts)
+
+			if len(res) != len(hl.Hosts) {
+				t.Fatalf("expected %d results, got %d instead\n", len(hl.Hosts), len(res))
+			}
+
+			for i, r := range res {
+				if r.Host != hl.Hosts[i] {
+					t.Errorf("expected host %q, got %q instead\n", hl.Hosts[i], r.Host)
+				}
+
+				if len(r.PortState) != len(ports) {
+					t.Fatalf("expected %d port states, got %d instead\n", len(ports), len(r.PortState))
+				}
+
+				for j, p := range r.PortState {
+					if p.Port != ports[j] {
+						t.Errorf("expected port %d, got %d instead\n", ports[j], p.Port)
+					}
+
+					if p.State.String() != expectState[p.Port] {
+						t.Errorf("expected port %d on %s to be %s\n", p.Port, r.Host, expectState[p.Port])
+					}
+				}
+			}
+		})
+	}
+}
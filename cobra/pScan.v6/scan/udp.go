@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// dnsProbe is a standard query for the name servers of the root zone
+var dnsProbe = []byte{
+	0x12, 0x34, // ID
+	0x01, 0x00, // recursion desired
+	0x00, 0x01, // 1 question
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00,       // root name
+	0x00, 0x02, // type NS
+	0x00, 0x01, // class IN
+}
+
+// ntpProbe is an NTP version 3 client request
+var ntpProbe = append([]byte{0x1b}, make([]byte, 47)...)
+
+// snmpProbe is an SNMPv1 get-request of sysDescr.0 with the public
+// community
+var snmpProbe = []byte{
+	0x30, 0x29, // message
+	0x02, 0x01, 0x00, // version 1
+	0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c', // community
+	0xa0, 0x1c, // get-request
+	0x02, 0x04, 0x12, 0x34, 0x56, 0x78, // request ID
+	0x02, 0x01, 0x00, // error status
+	0x02, 0x01, 0x00, // error index
+	0x30, 0x0e, // variable bindings
+	0x30, 0x0c,
+	0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, // sysDescr.0
+	0x05, 0x00, // null
+}
+
+// udpProbes holds the payloads most likely to get an answer from the
+// service listening on a port. Other ports get an empty datagram
+var udpProbes = map[int][]byte{
+	53:  dnsProbe,
+	123: ntpProbe,
+	161: snmpProbe,
+}
+
+// scanUDPPort sends a probe to a single UDP port. An answer means the
+// port is open, an ICMP port unreachable error that it is closed and
+// other ICMP unreachable errors that it is filtered. Without any, the
+// port is reported as open|filtered
+func scanUDPPort(host string, port int, timeout time.Duration) PortState {
+	p := PortState{
+		Port:     port,
+		Protocol: "udp",
+		State:    OpenFiltered,
+	}
+
+	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+
+	// Connecting the socket makes the ICMP errors for the address
+	// show up on the next read
+	scanConn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		p.State = Filtered
+		return p
+	}
+	defer scanConn.Close()
+
+	if err := scanConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return p
+	}
+
+	_, err = scanConn.Write(udpProbes[port])
+	if err == nil {
+		buf := make([]byte, 512)
+		_, err = scanConn.Read(buf)
+	}
+
+	switch {
+	case err == nil:
+		p.State = Open
+	case errors.Is(err, syscall.ECONNREFUSED):
+		p.State = Closed
+	case unreachable(err):
+		p.State = Filtered
+	}
+
+	return p
+}
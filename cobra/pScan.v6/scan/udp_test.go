@@ -0,0 +1,92 @@
+package scan_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"cobra/pScan.v6/scan"
+)
+
+func TestRunUDP(t *testing.T) {
+	testCases := []struct {
+		name        string
+		expectState string
+	}{
+		{"OpenPort", "open"},
+		{"SilentPort", "open|filtered"},
+		{"ClosedPort", "closed"},
+	}
+
+	host := "127.0.0.1"
+
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	ports := []int{}
+	probes := make(chan []byte, 1)
+
+	// Init ports, 0 answering, 1 not answering, 2 closed
+	for _, tc := range testCases {
+		conn, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		ports = append(ports, conn.LocalAddr().(*net.UDPAddr).Port)
+
+		switch tc.name {
+		case "OpenPort":
+			go func() {
+				buf := make([]byte, 512)
+
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+
+				probes <- buf[:n]
+				conn.WriteTo([]byte("pong"), addr)
+			}()
+		case "ClosedPort":
+			conn.Close()
+		}
+	}
+
+	res := scan.RunOptions(hl, ports, scan.Options{UDP: true, Timeout: 300 * time.Millisecond})
+
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %d instead\n", len(res))
+	}
+
+	if len(res[0].PortState) != len(ports) {
+		t.Fatalf("expected %d port states, got %d instead\n", len(ports), len(res[0].PortState))
+	}
+
+	for i, tc := range testCases {
+		ps := res[0].PortState[i]
+
+		if ps.Port != ports[i] {
+			t.Errorf("expected port %d, got %d instead\n", ports[i], ps.Port)
+		}
+
+		if ps.Protocol != "udp" {
+			t.Errorf("expected protocol %q, got %q instead\n", "udp", ps.Protocol)
+		}
+
+		if ps.State.String() != tc.expectState {
+			t.Errorf("%s: expected port %d to be %s, got %s instead\n", tc.name, ports[i], tc.expectState, ps.State)
+		}
+	}
+
+	select {
+	case p := <-probes:
+		if len(p) != 0 {
+			t.Errorf("expected an empty probe for an unknown port, got %d bytes instead\n", len(p))
+		}
+	default:
+		t.Error("expected the open port to receive a probe")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// stepCache remembers successful runs of a step keyed by a hash of its
+// definition, command and declared inputs, so the step can be skipped
+// while none of them change. def is the serialized step definition
+type stepCache struct {
+	dir     string
+	proj    string
+	name    string
+	command string
+	def     []byte
+	files   []string
+	env     []string
+}
+
+// key hashes the project directory, the step definition and command, the
+// Go toolchain version, the value of the declared environment variables
+// and the path and content of every file matching the declared globs
+func (c stepCache) key() (string, error) {
+	proj, err := filepath.Abs(c.proj)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	fmt.Fprintf(h, "proj %q\nstep %q\ncommand %q\ndef %q\ngo %q\n", proj, c.name, c.command, c.def, goVersion(c.proj))
+
+	for _, e := range c.env {
+		fmt.Fprintf(h, "env %q=%q\n", e, os.Getenv(e))
+	}
+
+	files, err := matchFiles(c.proj, c.files)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		fmt.Fprintf(h, "file %q\n", f)
+
+		if err := hashFile(h, filepath.Join(c.proj, filepath.FromSlash(f))); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// goVersion returns the version of the Go toolchain used in proj, or of
+// the one goci was built with when the go command is not available
+func goVersion(proj string) string {
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Dir = proj
+
+	out, err := cmd.Output()
+	if err != nil {
+		return runtime.Version()
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+func hashFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// lookup returns the success message of a previous run with the same key
+func (c stepCache) lookup(key string) (string, bool) {
+	msg, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		return "", false
+	}
+
+	return string(msg), true
+}
+
+// store records the success message of a run with key
+func (c stepCache) store(key, msg string) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(c.dir, key), []byte(msg), 0644)
+}
+
+// matchFiles returns the sorted slash separated paths, relative to proj,
+// of the files matching any of patterns. Hidden directories are skipped
+func matchFiles(proj string, patterns []string) ([]string, error) {
+	var files []string
+
+	if len(patterns) == 0 {
+		return files, nil
+	}
+
+	err := filepath.WalkDir(proj, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != proj && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(proj, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, pattern := range patterns {
+			if matchGlob(pattern, rel) {
+				files = append(files, rel)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	sort.Strings(files)
+	return files, err
+}
+
+// matchGlob reports whether the slash separated name matches pattern.
+// Besides the path.Match syntax, a ** element matches any number of
+// directories
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// validGlob reports whether pattern is syntactically valid
+func validGlob(pattern string) bool {
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return false
+		}
+	}
+
+	return pattern != ""
+}
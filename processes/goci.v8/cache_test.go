@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	var testCases = []struct {
+		pattern string
+		name    string
+		exp     bool
+	}{
+		{"*.go", "add.go", true},
+		{"*.go", "cmd/add.go", false},
+		{"**/*.go", "add.go", true},
+		{"**/*.go", "cmd/sub/add.go", true},
+		{"**/*.go", "go.mod", false},
+		{"**/testdata/**", "testdata/in.txt", true},
+		{"**/testdata/**", "pkg/testdata/a/in.txt", true},
+		{"**/testdata/**", "pkg/data/in.txt", false},
+		{"go.mod", "go.mod", true},
+		{"go.mod", "sub/go.mod", false},
+	}
+
+	for _, tc := range testCases {
+		if got := matchGlob(tc.pattern, tc.name); got != tc.exp {
+			t.Errorf("matchGlob(%q, %q): expected %t. got %t.", tc.pattern, tc.name, tc.exp, got)
+		}
+	}
+}
+
+func TestStepCacheKey(t *testing.T) {
+	proj := t.TempDir()
+	src := filepath.Join(proj, "add.go")
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(proj, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("add.go", "package add\n")
+	write("notes.txt", "notes\n")
+
+	c := stepCache{dir: t.TempDir(), proj: proj, name: "go build", command: "go build", def: []byte(`{"kind":"coverage","threshold":50}`), files: []string{"**/*.go"}, env: []string{"GOCI_TEST_INPUT"}}
+
+	key := func() string {
+		t.Helper()
+		k, err := c.key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	k1 := key()
+
+	write("notes.txt", "changed\n")
+	if k := key(); k != k1 {
+		t.Errorf("expected key to ignore files not matching inputs")
+	}
+
+	if err := os.WriteFile(src, []byte("package add\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	k2 := key()
+	if k2 == k1 {
+		t.Errorf("expected key to change with input file content")
+	}
+
+	t.Setenv("GOCI_TEST_INPUT", "1")
+	if k := key(); k == k2 {
+		t.Errorf("expected key to change with input environment")
+	}
+
+	k3 := key()
+
+	c.command = "go build -race"
+	k4 := key()
+	if k4 == k3 {
+		t.Errorf("expected key to change with command")
+	}
+
+	c.def = []byte(`{"kind":"coverage","threshold":90}`)
+	if k := key(); k == k4 {
+		t.Errorf("expected key to change with step definition")
+	}
+}
+
+func TestBuildCacheKey(t *testing.T) {
+	def := pipelineDef{Steps: []stepDef{{Name: "cover", Exe: "go", Args: []string{"test", "-cover"}, Kind: kindCoverage, Threshold: 50, Inputs: goInputs}}}
+
+	key := func() string {
+		t.Helper()
+
+		tasks := def.build("./testdata/tool", io.Discard, config{cacheDir: t.TempDir()})
+		if tasks[0].cache == nil {
+			t.Fatal("expected cacheable step")
+		}
+
+		k, err := tasks[0].cache.key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	k := key()
+
+	def.Steps[0].Threshold = 90
+	if key() == k {
+		t.Errorf("expected key to change with the coverage threshold")
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	cfg := config{jobs: 4, noPush: true, cacheDir: cacheDir}
+
+	var testCases = []struct {
+		name       string
+		clearCache bool
+		out        string
+	}{
+		{name: "firstRun", out: "Go Build: SUCCESS\nGo Test: SUCCESS\nGofmt: SUCCESS\ngit push: SKIPPED\n"},
+		{name: "cached", out: "Go Build: SUCCESS (cached)\nGo Test: SUCCESS (cached)\nGofmt: SUCCESS (cached)\ngit push: SKIPPED\n"},
+		{name: "clearCache", clearCache: true, out: "Go Build: SUCCESS\nGo Test: SUCCESS\nGofmt: SUCCESS\ngit push: SKIPPED\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg.clearCache = tc.clearCache
+
+			var out bytes.Buffer
+			if err := run("./testdata/tool", &out, cfg); err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if out.String() != tc.out {
+				t.Errorf("expected output: %q. got %q.", tc.out, out.String())
+			}
+		})
+	}
+}
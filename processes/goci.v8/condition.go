@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// condition restricts a step to a branch and to changes of the files
+// matching any of the changed globs. Empty fields always hold
+type condition struct {
+	proj    string
+	branch  string
+	changed []string
+}
+
+// met reports whether every part of the condition holds
+func (c condition) met(ctx context.Context) (bool, error) {
+	if c.branch != "" {
+		branch, err := currentBranch(ctx, c.proj)
+		if err != nil {
+			return false, err
+		}
+
+		if ok, _ := path.Match(c.branch, branch); !ok {
+			return false, nil
+		}
+	}
+
+	if len(c.changed) > 0 {
+		files, err := changedFiles(ctx, c.proj)
+		if err != nil {
+			return false, err
+		}
+
+		for _, f := range files {
+			for _, pattern := range c.changed {
+				if matchGlob(pattern, f) {
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// changedFiles returns the files of proj, relative to it, changed by the
+// last commit or since. Every tracked file is changed when the
+// repository has a single commit
+func changedFiles(ctx context.Context, proj string) ([]string, error) {
+	out, err := gitOutput(ctx, proj, "diff", "--name-only", "--relative", "HEAD~1", "--")
+	if err != nil {
+		if _, hErr := gitOutput(ctx, proj, "rev-parse", "--verify", "--quiet", "HEAD~1"); hErr == nil {
+			return nil, err
+		}
+
+		out, err = gitOutput(ctx, proj, "ls-files")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if out == "" {
+		return nil, nil
+	}
+
+	return strings.Split(out, "\n"), nil
+}
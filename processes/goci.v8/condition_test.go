@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestConditionMet(t *testing.T) {
+	gitExec, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("Git not installed. Skipping test")
+	}
+
+	proj := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.Command(gitExec, args...)
+		cmd.Dir = proj
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com")
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	write := func(name string) {
+		t.Helper()
+
+		path := filepath.Join(proj, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-b", "main")
+	write("README.md")
+	write("cmd/main.go")
+	git("add", ".")
+	git("commit", "-m", "first")
+
+	var testCases = []struct {
+		name   string
+		setup  func()
+		cond   condition
+		expMet bool
+	}{
+		{name: "branch", cond: condition{branch: "main"}, expMet: true},
+		{name: "branchGlob", cond: condition{branch: "ma*"}, expMet: true},
+		{name: "otherBranch", cond: condition{branch: "release/*"}, expMet: false},
+		{name: "firstCommitChangesAll", cond: condition{changed: []string{"**/*.go"}}, expMet: true},
+		{
+			name: "changedInLastCommit",
+			setup: func() {
+				write("docs/guide.md")
+				git("add", ".")
+				git("commit", "-m", "docs")
+			},
+			cond:   condition{changed: []string{"docs/**"}},
+			expMet: true,
+		},
+		{name: "unchanged", cond: condition{changed: []string{"**/*.go"}}, expMet: false},
+		{name: "branchAndUnchanged", cond: condition{branch: "main", changed: []string{"**/*.go"}}, expMet: false},
+		{
+			name: "uncommittedChange",
+			setup: func() {
+				if err := os.WriteFile(filepath.Join(proj, "cmd", "main.go"), []byte("changed"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			cond:   condition{changed: []string{"cmd/*.go"}},
+			expMet: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.setup != nil {
+				tc.setup()
+			}
+
+			tc.cond.proj = proj
+
+			met, err := tc.cond.met(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if met != tc.expMet {
+				t.Errorf("expected condition met to be %t. got %t.", tc.expMet, met)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// coverageStep runs go test with a coverage profile and fails when the
+// percentage of covered statements is below threshold
+type coverageStep struct {
+	step
+	threshold float64
+}
+
+func newCoverageStep(name, exe, message, proj string, args []string, threshold float64) coverageStep {
+	s := coverageStep{}
+	s.step = newStep(name, exe, message, proj, args)
+	s.threshold = threshold
+	return s
+}
+
+func (s coverageStep) execute(ctx context.Context) (stepResult, error) {
+	profile, err := os.CreateTemp("", "goci-cover-*.out")
+	if err != nil {
+		return stepResult{}, &stepErr{step: s.name, msg: "failed to create coverage profile", cause: err, exitCode: -1}
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	args := append(append([]string{}, s.args...), "-coverprofile="+profile.Name())
+
+	cmd := exec.CommandContext(ctx, s.exe, args...)
+
+	output, err := s.run(ctx, cmd, nil)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+	if err != nil {
+		return res, err
+	}
+
+	f, err := os.Open(profile.Name())
+	if err != nil {
+		return res, &stepErr{step: s.name, msg: "failed to read coverage profile", cause: err, output: output, exitCode: -1}
+	}
+	defer f.Close()
+
+	cov, err := parseCoverProfile(f)
+	if err != nil {
+		return res, &stepErr{step: s.name, msg: "failed to parse coverage profile", cause: err, output: output, exitCode: -1}
+	}
+
+	if cov < s.threshold {
+		return res, &stepErr{
+			step:     s.name,
+			msg:      fmt.Sprintf("coverage %.1f%% below threshold %.1f%%", cov, s.threshold),
+			cause:    ErrCoverage,
+			output:   output,
+			exitCode: -1,
+		}
+	}
+
+	res.msg = fmt.Sprintf("%s (coverage: %.1f%%)", s.message, cov)
+	return res, nil
+}
+
+// parseCoverProfile returns the percentage of statements covered in a
+// go test coverage profile. Blocks listed more than once, as happens
+// with -coverpkg, count as covered when any of their entries is. A
+// profile without statements is fully covered
+func parseCoverProfile(r io.Reader) (float64, error) {
+	type block struct {
+		stmts   int
+		covered bool
+	}
+
+	blocks := make(map[string]block)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+
+		if n == 1 {
+			if !strings.HasPrefix(line, "mode: ") {
+				return 0, fmt.Errorf("missing mode line")
+			}
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+
+		// file.go:startLine.startCol,endLine.endCol numStmts count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return 0, fmt.Errorf("line %d: invalid block %q", n, line)
+		}
+
+		stmts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: invalid statement count %q", n, fields[1])
+		}
+
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: invalid hit count %q", n, fields[2])
+		}
+
+		b := blocks[fields[0]]
+		b.stmts = stmts
+		b.covered = b.covered || count > 0
+		blocks[fields[0]] = b
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	total, covered := 0, 0
+	for _, b := range blocks {
+		total += b.stmts
+		if b.covered {
+			covered += b.stmts
+		}
+	}
+
+	if total == 0 {
+		return 100, nil
+	}
+
+	return 100 * float64(covered) / float64(total), nil
+}
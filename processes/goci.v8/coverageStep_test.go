@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		profile string
+		exp     float64
+		expErr  bool
+	}{
+		{name: "covered", profile: "mode: set\na.go:3.24,5.2 1 1\na.go:7.24,9.2 1 1\n", exp: 100},
+		{name: "partial", profile: "mode: set\na.go:3.24,5.2 3 1\na.go:7.24,9.2 1 0\n", exp: 75},
+		{name: "duplicateBlocks", profile: "mode: set\na.go:3.24,5.2 1 0\na.go:7.24,9.2 1 0\na.go:3.24,5.2 1 1\n", exp: 50},
+		{name: "noStatements", profile: "mode: set\n", exp: 100},
+		{name: "missingMode", profile: "a.go:3.24,5.2 1 1\n", expErr: true},
+		{name: "invalidBlock", profile: "mode: set\na.go:3.24,5.2 x 1\n", expErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cov, err := parseCoverProfile(strings.NewReader(tc.profile))
+
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("expected error. got coverage %.1f.", cov)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if cov != tc.exp {
+				t.Errorf("expected coverage %.1f. got %.1f.", tc.exp, cov)
+			}
+		})
+	}
+}
+
+func TestCoverageStep(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		proj      string
+		threshold float64
+		expMsg    string
+		expErr    error
+	}{
+		{name: "aboveThreshold", proj: "./testdata/tool", threshold: 100, expMsg: "Coverage: SUCCESS (coverage: 100.0%)"},
+		{name: "belowThreshold", proj: "./testdata/toolCover", threshold: 80, expErr: ErrCoverage},
+		{name: "testFailure", proj: "./testdata/toolerr", threshold: 0, expErr: &stepErr{step: "coverage"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newCoverageStep("coverage", "go", "Coverage: SUCCESS", tc.proj, []string{"test"}, tc.threshold)
+
+			res, err := s.execute(context.Background())
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if res.msg != tc.expMsg {
+				t.Errorf("expected message %q. got %q.", tc.expMsg, res.msg)
+			}
+		})
+	}
+}
@@ -3,17 +3,30 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os/exec"
 )
 
 var (
-	ErrValidation = errors.New("validation failed")
-	ErrSignal     = errors.New("received signal")
+	ErrValidation  = errors.New("validation failed")
+	ErrSignal      = errors.New("received signal")
+	ErrDirtyTree   = errors.New("working tree has uncommitted changes")
+	ErrCoverage    = errors.New("coverage below threshold")
+	ErrFindings    = errors.New("found issues")
+	ErrOutputMatch = errors.New("unexpected output")
+	ErrHookExists  = errors.New("hook already exists")
+	ErrFormat      = errors.New("files not formatted")
+	ErrBudget      = errors.New("pipeline budget exceeded")
+	ErrTests       = errors.New("tests failed")
 )
 
 type stepErr struct {
-	step  string
-	msg   string
-	cause error
+	step     string
+	msg      string
+	cause    error
+	output   string
+	exitCode int
+	attempts int
+	findings []finding
 }
 
 func (s *stepErr) Error() string {
@@ -32,3 +45,14 @@ func (s *stepErr) Is(target error) bool {
 func (s *stepErr) Unwrap() error {
 	return s.cause
 }
+
+// exitCode returns the exit code of the command that caused err, or -1
+// when the command did not run or was terminated by a signal
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
+	"strings"
 )
 
 type exceptionStep struct {
@@ -16,29 +18,40 @@ func newExceptionStep(name, exe, message, proj string, args []string) exceptionS
 	return s
 }
 
-func (s exceptionStep) execute() (string, error) {
-	cmd := exec.Command(s.exe, s.args...)
+// unformatted returns a finding for every file listed in out
+func unformatted(out string) []finding {
+	var findings []finding
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
+	for _, f := range strings.Split(strings.TrimSpace(out), "\n") {
+		if f = strings.TrimSpace(f); f != "" {
+			findings = append(findings, finding{File: f, Message: "not formatted"})
+		}
+	}
+
+	return findings
+}
 
-	cmd.Dir = s.proj
+func (s exceptionStep) execute(ctx context.Context) (stepResult, error) {
+	cmd := exec.CommandContext(ctx, s.exe, s.args...)
 
-	if err := cmd.Run(); err != nil {
-		return "", &stepErr{
-			step:  s.name,
-			msg:   "failed to execute",
-			cause: err,
-		}
+	var out bytes.Buffer
+
+	output, err := s.run(ctx, cmd, &out)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+	if err != nil {
+		return res, err
 	}
 
 	if out.Len() > 0 {
-		return "", &stepErr{
-			step:  s.name,
-			msg:   fmt.Sprintf("invalid format: %s", out.String()),
-			cause: nil,
+		return res, &stepErr{
+			step:     s.name,
+			msg:      fmt.Sprintf("invalid format: %s", s.secrets.mask(out.String())),
+			cause:    ErrFormat,
+			output:   output,
+			findings: unformatted(out.String()),
 		}
 	}
 
-	return s.message, nil
+	res.msg = s.message
+	return res, nil
 }
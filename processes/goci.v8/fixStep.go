@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// stepFixed is returned by a step that found issues and fixed them, so
+// the run records the step as fixed rather than successful. It carries
+// the success message and the changes made and is not a failure
+type stepFixed struct {
+	msg  string
+	diff string
+}
+
+func (f *stepFixed) Error() string {
+	return f.msg + " (fixed)"
+}
+
+// fixStep runs a fix command on the files reported by an exceptionStep
+// failing with invalid format, shows the changes and checks them again,
+// optionally staging them with git
+type fixStep struct {
+	executer
+	name  string
+	exe   string
+	args  []string
+	proj  string
+	stage bool
+	out   io.Writer
+}
+
+func newFixStep(e executer, name, exe string, args []string, proj string, stage bool, out io.Writer) fixStep {
+	return fixStep{
+		executer: e,
+		name:     name,
+		exe:      exe,
+		args:     args,
+		proj:     proj,
+		stage:    stage,
+		out:      out,
+	}
+}
+
+func (s fixStep) execute(ctx context.Context) (stepResult, error) {
+	res, err := s.executer.execute(ctx)
+
+	var sErr *stepErr
+	if !errors.Is(err, ErrFormat) || !errors.As(err, &sErr) || len(sErr.findings) == 0 {
+		return res, err
+	}
+
+	files := make([]string, len(sErr.findings))
+	before := make([][]byte, len(files))
+
+	for i, f := range sErr.findings {
+		files[i] = f.File
+
+		if before[i], err = os.ReadFile(filepath.Join(s.proj, f.File)); err != nil {
+			return res, s.fixErr("failed to read", err, "")
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, s.exe, append(append([]string{}, s.args...), files...)...)
+	cmd.Dir = s.proj
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return res, s.fixErr("failed to fix", err, string(out))
+	}
+
+	var diff strings.Builder
+	for i, f := range files {
+		after, err := os.ReadFile(filepath.Join(s.proj, f))
+		if err != nil {
+			return res, s.fixErr("failed to read", err, "")
+		}
+
+		d, err := fileDiff(ctx, f, before[i], after)
+		if err != nil {
+			return res, s.fixErr("failed to show fixes", err, "")
+		}
+
+		diff.WriteString(d)
+	}
+
+	fmt.Fprintf(s.out, "%s: fixed %d files\n%s", s.name, len(files), diff.String())
+
+	if s.stage {
+		if _, err := gitOutput(ctx, s.proj, append([]string{"add", "--"}, files...)...); err != nil {
+			return res, s.fixErr("failed to stage fixes", err, "")
+		}
+	}
+
+	next, err := s.executer.execute(ctx)
+	res = res.then(next)
+	if err != nil {
+		return res, err
+	}
+
+	return res, &stepFixed{msg: res.msg, diff: diff.String()}
+}
+
+// fileDiff returns the unified diff, as shown by git diff, of the changes
+// made to the file name from before to after
+func fileDiff(ctx context.Context, name string, before, after []byte) (string, error) {
+	dir, err := os.MkdirTemp("", "goci-diff")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	// Lay out both versions as a/name and b/name so the diff headers
+	// name the file as git does
+	for side, data := range map[string][]byte{"a": before, "b": after} {
+		path := filepath.Join(dir, side, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "diff", "--no-index", "--no-color", "--no-ext-diff", "--no-prefix",
+		"--", filepath.Join("a", name), filepath.Join("b", name))
+	cmd.Dir = dir
+
+	// git diff exits with 1 when the files differ
+	out, err := cmd.Output()
+	if exitCode(err) == 1 {
+		err = nil
+	}
+
+	return string(out), err
+}
+
+func (s fixStep) fixErr(msg string, err error, output string) error {
+	return &stepErr{
+		step:     s.name,
+		msg:      msg,
+		cause:    err,
+		output:   output,
+		exitCode: exitCode(err),
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFix(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		stage bool
+	}{
+		{name: "fix"},
+		{name: "fixAndStage", stage: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("Git not installed. Skipping test")
+			}
+
+			proj := t.TempDir()
+			copyModule(t, filepath.Join("testdata", "toolFmtErr"), proj)
+
+			if tc.stage {
+				cleanup := setupGit(t, proj)
+				defer cleanup()
+			}
+
+			report := filepath.Join(t.TempDir(), "report.json")
+
+			var out bytes.Buffer
+			err := run(proj, &out, config{jobs: 1, noPush: true, fix: true, fixStage: tc.stage, steps: []string{"go fmt"}, report: report})
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			for _, exp := range []string{"go fmt: fixed 1 files\n", "--- a/add.go\n+++ b/add.go\n", "-return a + b\n+\treturn a + b\n", "Gofmt: SUCCESS (fixed)\n"} {
+				if !strings.Contains(out.String(), exp) {
+					t.Errorf("expected output to contain %q. got %q.", exp, out.String())
+				}
+			}
+
+			if out, err := exec.Command("gofmt", "-l", proj).Output(); err != nil || len(out) > 0 {
+				t.Errorf("expected project to be formatted. got %q, %v.", out, err)
+			}
+
+			data, err := os.ReadFile(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rep runReport
+			if err := json.Unmarshal(data, &rep); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := rep.Steps[0]; s.Status != statusFixed || !strings.Contains(s.Output, "+++ b/add.go") {
+				t.Errorf("expected step to be reported as fixed with its diff. got %+v.", s)
+			}
+
+			if tc.stage {
+				staged, err := gitOutput(context.Background(), proj, "diff", "--cached", "--name-only")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if staged != "add.go" {
+					t.Errorf("expected add.go to be staged. got %q.", staged)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// runRecord is a pipeline run kept in the history
+type runRecord struct {
+	ID       int          `json:"id"`
+	Trigger  string       `json:"trigger"`
+	Start    time.Time    `json:"start"`
+	Duration float64      `json:"duration"`
+	Status   string       `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Steps    []stepReport `json:"steps"`
+	Log      string       `json:"log,omitempty"`
+}
+
+// newRunRecord creates the record of a run started at start, based on
+// its report when the pipeline could be loaded
+func newRunRecord(trigger string, start time.Time, rep *runReport, err error, log string) runRecord {
+	rec := runRecord{
+		Trigger:  trigger,
+		Start:    start,
+		Duration: time.Since(start).Seconds(),
+		Status:   statusSuccess,
+		Log:      log,
+	}
+
+	if rep != nil {
+		rec.Start = rep.Start
+		rec.Duration = rep.Duration
+		rec.Status = rep.Status
+		rec.Steps = rep.Steps
+	}
+
+	if err != nil {
+		rec.Error = err.Error()
+		if rep == nil {
+			rec.Status = statusFailed
+		}
+	}
+
+	return rec
+}
+
+// history stores run records as JSON lines appended to a file. It is
+// safe for concurrent use
+type history struct {
+	mu   sync.Mutex
+	path string
+	runs []runRecord
+}
+
+// loadHistory reads the history stored at path, which may not exist yet
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		var rec runRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, n, err)
+		}
+
+		h.runs = append(h.runs, rec)
+	}
+
+	return h, scanner.Err()
+}
+
+// add assigns the next ID to rec and appends it to the history
+func (h *history) add(rec runRecord) (runRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rec.ID = 1
+	if n := len(h.runs); n > 0 {
+		rec.ID = h.runs[n-1].ID + 1
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return rec, err
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return rec, err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return rec, err
+	}
+
+	if err := f.Close(); err != nil {
+		return rec, err
+	}
+
+	h.runs = append(h.runs, rec)
+	return rec, nil
+}
+
+// list returns the records, most recent first, without their logs
+func (h *history) list() []runRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	runs := make([]runRecord, 0, len(h.runs))
+	for i := len(h.runs) - 1; i >= 0; i-- {
+		rec := h.runs[i]
+		rec.Log = ""
+		runs = append(runs, rec)
+	}
+
+	return runs
+}
+
+// get returns the record with id
+func (h *history) get(id int) (runRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, rec := range h.runs {
+		if rec.ID == id {
+			return rec, true
+		}
+	}
+
+	return runRecord{}, false
+}
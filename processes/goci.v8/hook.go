@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies the git hooks written by goci
+const hookMarker = "# Installed by goci hook install"
+
+// hookBackupSuffix is appended to the name of an existing hook replaced
+// with -force, so uninstalling restores it
+const hookBackupSuffix = ".goci-backup"
+
+// hookNames lists the git hooks goci can be installed as
+var hookNames = []string{"pre-commit", "pre-push"}
+
+// hook implements the goci hook install and uninstall commands
+func hook(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "install" && args[0] != "uninstall" {
+		return fmt.Errorf("usage: goci hook install|uninstall [flags]")
+	}
+
+	fs := flag.NewFlagSet("goci hook "+args[0], flag.ExitOnError)
+
+	proj := fs.String("p", "", "Project directory")
+	name := fs.String("hook", "pre-push", "Git hook to manage, one of "+strings.Join(hookNames, ", "))
+
+	var steps, gociPath *string
+	var force *bool
+	if args[0] == "install" {
+		steps = fs.String("steps", "", "Comma-separated steps the hook runs along with the steps they need (default all)")
+		gociPath = fs.String("goci", defaultGociPath(), "goci command the hook runs")
+		force = fs.Bool("force", false, "Replace an existing hook, keeping a backup restored on uninstall")
+	}
+
+	fs.Parse(args[1:])
+
+	if !validHook(*name) {
+		return fmt.Errorf("%w: unknown hook %q", ErrValidation, *name)
+	}
+
+	if args[0] == "uninstall" {
+		if err := uninstallHook(*proj, *name); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "Removed %s hook\n", *name)
+		return nil
+	}
+
+	path, err := installHook(*proj, *name, splitList(*steps), *gociPath, *force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Installed %s hook in %s\n", *name, path)
+	return nil
+}
+
+func validHook(name string) bool {
+	for _, h := range hookNames {
+		if h == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// defaultGociPath returns goci when it is in PATH, or the path of the
+// running executable otherwise
+func defaultGociPath() string {
+	if _, err := exec.LookPath("goci"); err == nil {
+		return "goci"
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		return exe
+	}
+
+	return "goci"
+}
+
+// hookScript returns a hook running the pipeline of the project at
+// prefix, relative to the repository root, limited to steps. Side
+// effects are always skipped as the hook runs during a git command
+func hookScript(gociPath, prefix string, steps []string) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "#!/bin/sh\n%s. Remove with goci hook uninstall.\n", hookMarker)
+	fmt.Fprintf(&b, "exec %s -p %s -no-push", shellQuote(gociPath), shellQuote(firstNonEmpty(prefix, ".")))
+
+	if len(steps) > 0 {
+		fmt.Fprintf(&b, " -steps %s", shellQuote(strings.Join(steps, ",")))
+	}
+
+	b.WriteString("\n")
+
+	return b.Bytes()
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hookPath returns the path of the git hook name for the repository of
+// proj, along with the path of proj relative to the repository root
+func hookPath(proj, name string) (string, string, error) {
+	ctx := context.Background()
+
+	dir, err := gitOutput(ctx, proj, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", "", fmt.Errorf("%s is not in a git repository: %w", firstNonEmpty(proj, "."), err)
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(proj, dir)
+	}
+
+	prefix, err := gitOutput(ctx, proj, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", "", err
+	}
+
+	return filepath.Join(dir, name), strings.TrimSuffix(prefix, "/"), nil
+}
+
+// isGociHook reports whether the hook at path was written by goci
+func isGociHook(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Contains(data, []byte(hookMarker)), nil
+}
+
+// installHook writes the git hook name running the steps of the project
+// pipeline, checked against it. An existing goci hook is updated while
+// any other hook is only replaced, after being backed up, with force and
+// when no previous backup exists
+func installHook(proj, name string, steps []string, gociPath string, force bool) (string, error) {
+	def, err := loadPipeline(proj)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := def.selectSteps(steps); err != nil {
+		return "", err
+	}
+
+	path, prefix, err := hookPath(proj, name)
+	if err != nil {
+		return "", err
+	}
+
+	ours, err := isGociHook(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return "", err
+	case !ours && !force:
+		return "", fmt.Errorf("%w: %s, use -force to replace it", ErrHookExists, path)
+	case !ours:
+		// Never overwrite the backup of the hook replaced first
+		backup := path + hookBackupSuffix
+		if _, err := os.Lstat(backup); err == nil {
+			return "", fmt.Errorf("%w: %s, backup %s already exists, remove or restore it first", ErrHookExists, path, backup)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		if err := os.Rename(path, backup); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	return path, os.WriteFile(path, hookScript(gociPath, prefix, steps), 0755)
+}
+
+// uninstallHook removes the git hook name written by goci, restoring
+// the hook it replaced if any
+func uninstallHook(proj, name string) error {
+	path, _, err := hookPath(proj, name)
+	if err != nil {
+		return err
+	}
+
+	ours, err := isGociHook(path)
+	if err != nil {
+		return err
+	}
+
+	if !ours {
+		return fmt.Errorf("%s was not installed by goci", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path + hookBackupSuffix); err == nil {
+		return os.Rename(path+hookBackupSuffix, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHookInstall(t *testing.T) {
+	gitExec, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("Git not installed. Skipping test")
+	}
+
+	repo := t.TempDir()
+	proj := filepath.Join(repo, "tools", "goci")
+
+	if err := os.MkdirAll(proj, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := exec.Command(gitExec, "-C", repo, "init").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+
+	path := filepath.Join(repo, ".git", "hooks", "pre-commit")
+
+	read := func() string {
+		t.Helper()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	if _, err := installHook(proj, "pre-commit", []string{"go bild"}, "goci", false); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected error: %q. got %q.", ErrValidation, err)
+	}
+
+	// Existing hooks are not replaced without force
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := installHook(proj, "pre-commit", nil, "goci", false); !errors.Is(err, ErrHookExists) {
+		t.Fatalf("expected error: %q. got %q.", ErrHookExists, err)
+	}
+
+	if _, err := installHook(proj, "pre-commit", []string{"go build", "go fmt"}, "goci", true); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+
+	exp := "exec 'goci' -p 'tools/goci' -no-push -steps 'go build,go fmt'\n"
+	if got := read(); !strings.HasSuffix(got, exp) {
+		t.Errorf("expected hook to end with %q. got %q.", exp, got)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("expected hook to be executable. got %v, %v.", info, err)
+	}
+
+	// Installing again updates the goci hook
+	if _, err := installHook(proj, "pre-commit", nil, "goci", false); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+
+	if got := read(); !strings.HasSuffix(got, "exec 'goci' -p 'tools/goci' -no-push\n") {
+		t.Errorf("expected hook to run every step. got %q.", got)
+	}
+
+	// A hook changed by hand is not replaced while the backup exists
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nmake test\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := installHook(proj, "pre-commit", nil, "goci", true); !errors.Is(err, ErrHookExists) {
+		t.Fatalf("expected error: %q. got %q.", ErrHookExists, err)
+	}
+
+	if got := read(); got != "#!/bin/sh\nmake test\n" {
+		t.Errorf("expected hook to be kept. got %q.", got)
+	}
+
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+hookMarker+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Uninstalling restores the replaced hook
+	if err := uninstallHook(proj, "pre-commit"); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+
+	if got := read(); got != "#!/bin/sh\nmake lint\n" {
+		t.Errorf("expected original hook to be restored. got %q.", got)
+	}
+
+	if err := uninstallHook(proj, "pre-commit"); err == nil {
+		t.Error("expected error uninstalling a hook not written by goci")
+	}
+}
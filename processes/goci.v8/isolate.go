@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// isolateCopy runs steps in a copy of the project files git does
+	// not ignore, or of every file outside hidden directories when the
+	// project is not in a git repository
+	isolateCopy = "copy"
+
+	// isolateWorktree runs steps in a git worktree checked out at HEAD,
+	// leaving out uncommitted changes
+	isolateWorktree = "worktree"
+)
+
+// checkIsolation checks the isolation mode of cfg, the empty string
+// disabling isolation. Fixes cannot be checked in a worktree at HEAD
+func checkIsolation(cfg config) error {
+	switch cfg.isolate {
+	case "", isolateCopy:
+	case isolateWorktree:
+		if cfg.fix {
+			return fmt.Errorf("%w: fix mode cannot run in a worktree", ErrValidation)
+		}
+	default:
+		return fmt.Errorf("%w: unknown isolation mode %q", ErrValidation, cfg.isolate)
+	}
+
+	return nil
+}
+
+// isolatedDir creates a fresh copy of the project proj according to
+// mode. It returns the directory matching proj in the copy along with a
+// function removing the copy
+func isolatedDir(ctx context.Context, mode, proj string) (string, func(), error) {
+	switch mode {
+	case isolateCopy:
+		return copyProject(ctx, proj)
+	case isolateWorktree:
+		return addWorktree(ctx, proj)
+	}
+
+	return "", nil, fmt.Errorf("unknown isolation mode %q", mode)
+}
+
+// copyProject copies the files of proj to a temporary directory
+func copyProject(ctx context.Context, proj string) (string, func(), error) {
+	files, err := projectFiles(ctx, proj)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "goci-isolated-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() { os.RemoveAll(dir) }
+
+	for _, f := range files {
+		if err := copyFile(filepath.Join(proj, f), filepath.Join(dir, f)); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	return dir, cleanup, nil
+}
+
+// projectFiles returns the slash separated paths, relative to proj, of
+// the tracked and untracked files git does not ignore. Outside a git
+// repository it returns every file not in a hidden directory
+func projectFiles(ctx context.Context, proj string) ([]string, error) {
+	out, err := gitOutput(ctx, proj, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return matchFiles(proj, []string{"**"})
+	}
+
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+// copyFile copies the regular file or symbolic link src to dst, keeping
+// its permissions. Files listed by git but deleted from the working tree
+// and directories, such as submodules, are skipped
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+
+		return os.Symlink(target, dst)
+	case !info.Mode().IsRegular():
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// addWorktree checks out HEAD of the repository holding proj in a
+// temporary git worktree
+func addWorktree(ctx context.Context, proj string) (string, func(), error) {
+	prefix, err := gitOutput(ctx, proj, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", nil, fmt.Errorf("not a git repository: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "goci-worktree-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := gitOutput(ctx, proj, "worktree", "add", "--detach", dir, "HEAD"); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("failed to add worktree: %w", err)
+	}
+
+	cleanup := func() {
+		// Remove the worktree even when the run was cancelled
+		gitOutput(context.WithoutCancel(ctx), proj, "worktree", "remove", "--force", dir)
+		os.RemoveAll(dir)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(prefix)), cleanup, nil
+}
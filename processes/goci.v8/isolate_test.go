@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunIsolated(t *testing.T) {
+	gitExec, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("Git not installed. Skipping test")
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed. Skipping test")
+	}
+
+	repo := t.TempDir()
+	proj := filepath.Join(repo, "mod")
+
+	git := func(args ...string) string {
+		t.Helper()
+
+		cmd := exec.Command(gitExec, args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com")
+
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+
+		return string(out)
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The step fails on leftover files and prints the state of the
+	// project it runs in
+	write(".gitignore", "junk\n")
+	write("mod/.goci.yaml", "steps:\n  - name: check\n    exe: sh\n    args: [\"-c\", \"test ! -e junk && cat state.txt && touch leftover\"]\n    message: \"Check: SUCCESS\"\n")
+	write("mod/state.txt", "committed\n")
+	git("init")
+	git("add", ".")
+	git("commit", "-m", "first")
+
+	write("mod/state.txt", "changed\n")
+	write("mod/junk", "junk\n")
+
+	var testCases = []struct {
+		name     string
+		isolate  string
+		expErr   error
+		expState string
+	}{
+		{name: "inPlace", isolate: "", expErr: &stepErr{step: "check"}},
+		{name: "copy", isolate: isolateCopy, expState: "changed"},
+		{name: "worktree", isolate: isolateWorktree, expState: "committed"},
+		{name: "unknownMode", isolate: "docker", expErr: ErrValidation},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(proj, &out, config{jobs: 1, verbose: true, isolate: tc.isolate})
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if exp := "[check] " + tc.expState + "\n"; !strings.Contains(out.String(), exp) {
+				t.Errorf("expected output to contain %q. got %q.", exp, out.String())
+			}
+
+			if _, err := os.Stat(filepath.Join(proj, "leftover")); !os.IsNotExist(err) {
+				t.Errorf("expected step not to write to the project. got %v.", err)
+			}
+
+			if n := strings.Count(git("worktree", "list", "--porcelain"), "worktree "); n != 1 {
+				t.Errorf("expected 1 worktree after the run. got %d.", n)
+			}
+		})
+	}
+}
@@ -1,99 +1,284 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 )
 
 type executer interface {
-	execute() (string, error)
+	execute(ctx context.Context) (stepResult, error)
+	commandLine() string
 }
 
-func run(proj string, out io.Writer) error {
-	pipeline := make([]executer, 4)
-
-	pipeline[0] = newStep(
-		"go build",
-		"go",
-		"Go Build: SUCCESS",
-		proj,
-		[]string{"build", ".", "errors"},
-	)
-
-	pipeline[1] = newStep(
-		"go test",
-		"go",
-		"Go Test: SUCCESS",
-		proj,
-		[]string{"test", "-v"},
-	)
-
-	pipeline[2] = newExceptionStep(
-		"go fmt",
-		"gofmt",
-		"Gofmt: SUCCESS",
-		proj,
-		[]string{"-l", "."},
-	)
-
-	pipeline[3] = newTimeoutStep(
-		"git push",
-		"git",
-		"Git Push: SUCCESS",
-		proj,
-		[]string{"push", "origin", "master"},
-		10*time.Second,
-	)
+type config struct {
+	// maximum number of steps running at the same time
+	jobs int
+
+	// stream the output of every step
+	verbose bool
+
+	// file to write the run report to
+	report string
+
+	// git remote and branch for side-effecting steps
+	remote string
+	branch string
+
+	// skip side-effecting steps
+	noPush bool
+
+	// rerun the pipeline on file changes, waiting debounce after the
+	// last change
+	watch    bool
+	debounce time.Duration
+
+	// directory holding cached step results, caching is disabled when
+	// empty. clearCache removes them before running
+	cacheDir   string
+	clearCache bool
+
+	// run the pipeline of every Go module found under the project
+	// directory, up to moduleJobs modules at a time
+	modules    bool
+	moduleJobs int
+
+	// fix the issues found by steps that support it, staging the
+	// changes with git when fixStage is set
+	fix      bool
+	fixStage bool
+
+	// run only these steps and the steps they depend on, every step
+	// when empty
+	steps []string
+
+	// time the whole run may take, overriding the pipeline budget when
+	// not zero
+	budget time.Duration
+
+	// run every step in a fresh copy of the project made according to
+	// this mode, in place when empty
+	isolate string
+
+	// time cancelled steps have to exit after SIGTERM before they are
+	// killed, defaultGracePeriod when zero
+	gracePeriod time.Duration
+}
+
+func run(proj string, out io.Writer, cfg config) error {
+	if err := checkIsolation(cfg); err != nil {
+		return err
+	}
+
+	if cfg.clearCache && cfg.cacheDir != "" {
+		if err := os.RemoveAll(cfg.cacheDir); err != nil {
+			return err
+		}
+	}
+
+	// Steps running concurrently share out
+	out = &syncWriter{w: out}
 
 	sig := make(chan os.Signal, 1)
 
-	errCh := make(chan error)
-	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
 
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
 
 	go func() {
-		for _, s := range pipeline {
-			msg, err := s.execute()
-			if err != nil {
-				errCh <- err
-				return
-			}
-
-			_, err = fmt.Fprintln(out, msg)
-			if err != nil {
-				errCh <- err
-				return
-			}
+		if cfg.watch {
+			errCh <- watch(ctx, proj, out, cfg)
+			return
 		}
-		close(done)
+
+		errCh <- runOnce(ctx, proj, out, cfg)
 	}()
 
-	for {
-		select {
-		case rec := <-sig:
-			signal.Stop(sig)
-			return fmt.Errorf("%s: Exiting: %w", rec, ErrSignal)
+	select {
+	case rec := <-sig:
+		signal.Stop(sig)
 
-		case err := <-errCh:
-			return err
-		case <-done:
-			return nil
+		// Stop the running steps and wait for the pipeline to exit
+		// so no child process is left behind
+		cancel()
+		<-errCh
+
+		return fmt.Errorf("%s: Exiting: %w", rec, ErrSignal)
+
+	case err := <-errCh:
+		return err
+	}
+}
+
+// runOnce runs the pipeline of proj, or of every module under it in
+// monorepo mode, once
+func runOnce(ctx context.Context, proj string, out io.Writer, cfg config) error {
+	if cfg.modules {
+		return runModules(ctx, proj, out, cfg)
+	}
+
+	return runPipeline(ctx, proj, out, cfg)
+}
+
+// runPipeline loads, builds and executes the project pipeline once,
+// sending its notifiers and saving the run report when requested
+func runPipeline(ctx context.Context, proj string, out io.Writer, cfg config) error {
+	rep, notify, err := execPipeline(ctx, proj, out, cfg)
+	notifyAll(ctx, notify, proj, rep, err, out)
+
+	if rep != nil && cfg.report != "" {
+		if rErr := rep.save(cfg.report); rErr != nil && err == nil {
+			err = rErr
 		}
 	}
+
+	return err
+}
+
+// execPipeline loads, builds and executes the project pipeline once,
+// returning its report along with the notifiers of the pipeline. Both
+// are nil when the pipeline could not be loaded
+func execPipeline(ctx context.Context, proj string, out io.Writer, cfg config) (*runReport, []notifyDef, error) {
+	def, err := loadPipeline(proj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(cfg.steps) > 0 {
+		if def, err = def.selectSteps(cfg.steps); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	budget := cfg.budget
+	if budget == 0 && def.Budget != "" {
+		// validated by loadPipeline
+		budget, _ = time.ParseDuration(def.Budget)
+	}
+
+	runCtx := ctx
+	if budget > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+
+	pipeline := def.build(proj, out, cfg)
+	rep := newRunReport(proj, pipeline)
+
+	err = runTasks(runCtx, pipeline, cfg.jobs, out, rep)
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %s: %w", ErrBudget, budget, err)
+	}
+	rep.finish(err)
+
+	return rep, def.Notify, err
+}
+
+// printErr writes err to w followed by the output of the failed step,
+// unless it was already streamed in verbose mode
+func printErr(w io.Writer, err error, verbose bool) {
+	fmt.Fprintln(w, err)
+
+	var sErr *stepErr
+	if !verbose && errors.As(err, &sErr) && sErr.output != "" {
+		fmt.Fprint(w, sErr.output)
+	}
+}
+
+// splitList splits a comma-separated list, ignoring empty elements
+func splitList(s string) []string {
+	var list []string
+
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+
+	return list
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		var cmd func(args []string, out io.Writer) error
+
+		switch os.Args[1] {
+		case "serve":
+			cmd = serve
+		case "hook":
+			cmd = hook
+		}
+
+		if cmd != nil {
+			if err := cmd(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	proj := flag.String("p", "", "Project directory")
+	jobs := flag.Int("j", runtime.NumCPU(), "Maximum number of steps to run concurrently")
+	verbose := flag.Bool("v", false, "Stream the output of every step")
+	report := flag.String("report", "", "Write a run report to this file, as JUnit XML for .xml files or JSON otherwise")
+	remote := flag.String("remote", "", "Git remote to push to (default \"origin\")")
+	branch := flag.String("branch", "", "Git branch to push (default current branch)")
+	noPush := flag.Bool("no-push", false, "Run every step except side-effecting ones such as git push")
+	watchMode := flag.Bool("watch", false, "Rerun the pipeline, except side-effecting steps, when project files change")
+	debounce := flag.Duration("debounce", 500*time.Millisecond, "Quiet period after a change before rerunning in watch mode")
+	cacheDir := flag.String("cache-dir", "", "Directory for cached step results (default no caching)")
+	clearCache := flag.Bool("clear-cache", false, "Remove cached step results before running")
+	modules := flag.Bool("modules", false, "Run the pipeline in every Go module under the project directory, pushing once from it")
+	moduleJobs := flag.Int("module-jobs", 1, "Maximum number of modules to run concurrently")
+	fix := flag.Bool("fix", false, "Fix the issues found by steps that support it, such as unformatted files, and check again")
+	fixStage := flag.Bool("fix-stage", false, "Stage the changes made by -fix with git add")
+	steps := flag.String("steps", "", "Comma-separated steps to run along with the steps they need (default all)")
+	isolate := flag.String("isolate", "", "Run every step in a fresh copy of the project: \"copy\" of the files git does not ignore, or \"worktree\" at HEAD")
+	grace := flag.Duration("grace", defaultGracePeriod, "Time interrupted steps have to exit after SIGTERM before they are killed")
+	budget := flag.Duration("budget", 0, "Time the whole run may take, overriding the pipeline budget (default no limit)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s serve [flags]\n       %s hook install|uninstall [flags]\n", os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	if err := run(*proj, os.Stdout); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	c := config{
+		jobs:        *jobs,
+		verbose:     *verbose,
+		report:      *report,
+		remote:      *remote,
+		branch:      *branch,
+		noPush:      *noPush,
+		watch:       *watchMode,
+		debounce:    *debounce,
+		cacheDir:    *cacheDir,
+		clearCache:  *clearCache,
+		modules:     *modules,
+		moduleJobs:  *moduleJobs,
+		fix:         *fix || *fixStage,
+		fixStage:    *fixStage,
+		steps:       splitList(*steps),
+		budget:      *budget,
+		isolate:     *isolate,
+		gracePeriod: *grace,
+	}
+
+	if err := run(*proj, os.Stdout, c); err != nil {
+		printErr(os.Stderr, err, c.verbose)
 		os.Exit(1)
 	}
 }
@@ -10,6 +10,10 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -23,9 +27,12 @@ func TestRun(t *testing.T) {
 		out      string
 		expErr   error
 		setupGit bool
+		noPush   bool
 		mockCmd  func(ctx context.Context, name string, arg ...string) *exec.Cmd
 	}{
 		{name: "success", proj: "./testdata/tool", out: "Go Build: SUCCESS\nGo Test: SUCCESS\nGofmt: SUCCESS\nGit Push: SUCCESS\n", expErr: nil, setupGit: true, mockCmd: nil},
+		{name: "successNoPush", proj: "./testdata/tool", out: "Go Build: SUCCESS\nGo Test: SUCCESS\nGofmt: SUCCESS\ngit push: SKIPPED\n", expErr: nil, setupGit: false, noPush: true, mockCmd: nil},
+		{name: "successPipelineFile", proj: "./testdata/toolPipeline", out: "Go Vet: SUCCESS\nGo Test: SUCCESS\n", expErr: nil, setupGit: false, mockCmd: nil},
 		{name: "fail", proj: "./testdata/toolErr", out: "", expErr: &stepErr{step: "go build"}, setupGit: false, mockCmd: mockCmdContext},
 		{name: "failFormat", proj: "./testdata/toolFmtErr", out: "", expErr: &stepErr{step: "go fmt"}, setupGit: false, mockCmd: nil},
 		{name: "failTimeout", proj: "./testdata/tool", out: "", expErr: context.DeadlineExceeded, setupGit: false, mockCmd: mockCmdTimeout},
@@ -44,12 +51,14 @@ func TestRun(t *testing.T) {
 				defer cleanup()
 			}
 
+			command = exec.CommandContext
 			if tc.mockCmd != nil {
 				command = tc.mockCmd
+				defer func() { command = exec.CommandContext }()
 			}
 
 			var out bytes.Buffer
-			err := run(tc.proj, &out)
+			err := run(tc.proj, &out, config{jobs: 4, noPush: tc.noPush})
 
 			if tc.expErr != nil {
 				if err == nil {
@@ -74,6 +83,80 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestRunOutput(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		verbose   bool
+		expOutput string
+		expStream string
+	}{
+		{name: "captured", verbose: false, expOutput: "undefined: c", expStream: ""},
+		{name: "verbose", verbose: true, expOutput: "undefined: c", expStream: "[go build] "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run("./testdata/toolerr", &out, config{jobs: 4, verbose: tc.verbose})
+
+			var sErr *stepErr
+			if !errors.As(err, &sErr) {
+				t.Fatalf("expected step error. got %q.", err)
+			}
+
+			if sErr.step != "go build" {
+				t.Errorf("expected step %q to fail. got %q.", "go build", sErr.step)
+			}
+
+			if !strings.Contains(sErr.output, tc.expOutput) {
+				t.Errorf("expected output to contain %q. got %q.", tc.expOutput, sErr.output)
+			}
+
+			if !strings.Contains(out.String(), tc.expStream) {
+				t.Errorf("expected stream to contain %q. got %q.", tc.expStream, out.String())
+			}
+
+			if !tc.verbose && strings.Contains(out.String(), "undefined: c") {
+				t.Errorf("expected no streamed output. got %q.", out.String())
+			}
+		})
+	}
+}
+
+func TestRunDirtyTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Git not installed. Skipping test")
+	}
+
+	proj := "./testdata/tool"
+
+	cleanup := setupGit(t, proj)
+	defer cleanup()
+
+	src := filepath.Join(proj, "add.go")
+
+	orig, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.WriteFile(src, orig, 0644)
+
+	if err := os.WriteFile(src, append(orig, []byte("\n// uncommitted\n")...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = run(proj, &out, config{jobs: 4})
+
+	if !errors.Is(err, ErrDirtyTree) {
+		t.Errorf("expected error: %q. got %q.", ErrDirtyTree, err)
+	}
+
+	if !errors.Is(err, &stepErr{step: "git push"}) {
+		t.Errorf("expected error for step %q. got %q.", "git push", err)
+	}
+}
+
 func setupGit(t *testing.T, proj string) func() {
 	t.Helper()
 
@@ -144,11 +227,98 @@ func mockCmdTimeout(ctx context.Context, exe string, args ...string) *exec.Cmd {
 	return cmd
 }
 
+func mockCmdIgnoreTerm(ctx context.Context, exe string, args ...string) *exec.Cmd {
+	cmd := mockCmdTimeout(ctx, exe, args...)
+	cmd.Env = append(cmd.Env, "GO_HELPER_IGNORE_TERM=1")
+	return cmd
+}
+
+// cmdTracker records the commands created by a mock to check they exited
+type cmdTracker struct {
+	mu   sync.Mutex
+	cmds []*exec.Cmd
+}
+
+func (c *cmdTracker) track(mock func(ctx context.Context, name string, arg ...string) *exec.Cmd) func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	return func(ctx context.Context, exe string, args ...string) *exec.Cmd {
+		cmd := mock(ctx, exe, args...)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.cmds = append(c.cmds, cmd)
+
+		return cmd
+	}
+}
+
+// started returns the number of tracked commands that were started
+func (c *cmdTracker) started() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, cmd := range c.cmds {
+		if cmd.Process != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// running returns the pids of tracked commands still running
+func (c *cmdTracker) running() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pids []int
+	for _, cmd := range c.cmds {
+		if cmd.Process == nil {
+			continue
+		}
+
+		if err := cmd.Process.Signal(syscall.Signal(0)); err == nil {
+			pids = append(pids, cmd.Process.Pid)
+		}
+	}
+
+	return pids
+}
+
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
 	}
 
+	// Start a grandchild ignoring SIGTERM, as go test does with the
+	// test binary, and record its pid
+	if pidFile := os.Getenv("GO_HELPER_GRANDCHILD"); pidFile != "" {
+		child := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "sleep")
+		child.Env = []string{"GO_WANT_HELPER_PROCESS=1", "GO_HELPER_IGNORE_TERM=1", "GO_HELPER_TIMEOUT=1"}
+		if err := child.Start(); err != nil {
+			os.Exit(1)
+		}
+
+		os.WriteFile(pidFile, []byte(strconv.Itoa(child.Process.Pid)), 0644)
+		time.Sleep(15 * time.Second)
+		os.Exit(0)
+	}
+
+	// Answer the working tree and branch queries of side-effecting steps
+	if os.Args[2] == "git" {
+		switch os.Args[3] {
+		case "status":
+			os.Exit(0)
+		case "rev-parse":
+			fmt.Fprintln(os.Stdout, "master")
+			os.Exit(0)
+		}
+	}
+
+	if os.Getenv("GO_HELPER_IGNORE_TERM") == "1" {
+		signal.Ignore(syscall.SIGTERM)
+	}
+
 	if os.Getenv("GO_HELPER_TIMEOUT") == "1" {
 		time.Sleep(15 * time.Second)
 	}
@@ -164,19 +334,23 @@ func TestHelperProcess(t *testing.T) {
 func TestRunKill(t *testing.T) {
 	// Runkill test Cases
 	var testCases = []struct {
-		name   string
-		proj   string
-		sig    syscall.Signal
-		expErr error
+		name    string
+		proj    string
+		sig     syscall.Signal
+		expErr  error
+		mockCmd func(ctx context.Context, name string, arg ...string) *exec.Cmd
 	}{
-		{"SIGINT", "./testdata/tool", syscall.SIGINT, ErrSignal},
-		{"SIGTERM", "./testdata/tool", syscall.SIGTERM, ErrSignal},
-		{"SIGQUIT", "./testdata/tool", syscall.SIGQUIT, nil},
+		{"SIGINT", "./testdata/tool", syscall.SIGINT, ErrSignal, mockCmdTimeout},
+		{"SIGTERM", "./testdata/tool", syscall.SIGTERM, ErrSignal, mockCmdTimeout},
+		{"SIGTERMIgnoredByChild", "./testdata/tool", syscall.SIGTERM, ErrSignal, mockCmdIgnoreTerm},
+		{"SIGQUIT", "./testdata/tool", syscall.SIGQUIT, nil, mockCmdTimeout},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			command = mockCmdTimeout
+			children := &cmdTracker{}
+			command = children.track(tc.mockCmd)
+			defer func() { command = exec.CommandContext }()
 
 			errCh := make(chan error)
 			ignSigCh := make(chan os.Signal, 1)
@@ -189,7 +363,7 @@ func TestRunKill(t *testing.T) {
 			defer signal.Stop(expSigCh)
 
 			go func() {
-				errCh <- run(tc.proj, io.Discard)
+				errCh <- run(tc.proj, io.Discard, config{jobs: 4, gracePeriod: time.Second})
 			}()
 			go func() {
 				time.Sleep(2 * time.Second)
@@ -216,6 +390,14 @@ func TestRunKill(t *testing.T) {
 					t.Errorf("expected error: %q. Got %q", tc.expErr, err)
 				}
 
+				if children.started() == 0 {
+					t.Errorf("expected child processes to be started")
+				}
+
+				if pids := children.running(); len(pids) > 0 {
+					t.Errorf("expected no child process left behind. Got %v", pids)
+				}
+
 				// Select signal
 				select {
 				case rec := <-expSigCh:
@@ -227,7 +409,83 @@ func TestRunKill(t *testing.T) {
 				}
 
 			case <-ignSigCh:
+				// Stop the run ignoring the signal so it does not
+				// outlive the test
+				process, err := os.FindProcess(os.Getpid())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := process.Signal(syscall.SIGINT); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := <-errCh; !errors.Is(err, ErrSignal) {
+					t.Errorf("expected error: %q. Got %q", ErrSignal, err)
+				}
 			}
 		})
 	}
 }
+
+func TestRunKillGrandchild(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process states are read from /proc. Skipping test")
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "pid")
+
+	s := newStep("go test", os.Args[0], "Go Test: SUCCESS", ".", []string{"-test.run=TestHelperProcess", "go", "test"})
+	s.env = []string{"GO_WANT_HELPER_PROCESS=1", "GO_HELPER_GRANDCHILD=" + pidFile}
+	s.grace = 500 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.execute(ctx)
+		errCh <- err
+	}()
+
+	var pid int
+	for start := time.Now(); pid == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("grandchild not started")
+		}
+
+		data, _ := os.ReadFile(pidFile)
+		pid, _ = strconv.Atoi(string(data))
+	}
+	defer func() {
+		if p, err := os.FindProcess(pid); err == nil {
+			p.Kill()
+		}
+	}()
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error: %q. got %q.", context.Canceled, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("step not stopped")
+	}
+
+	if running(pid) {
+		t.Errorf("expected grandchild %d to exit", pid)
+	}
+}
+
+// running reports whether process pid exists and is not a zombie
+func running(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+
+	_, state, _ := strings.Cut(string(data), ") ")
+	return !strings.HasPrefix(state, "Z")
+}
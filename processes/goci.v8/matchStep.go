@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+)
+
+// matchStep checks the standard output of a command against regular
+// expressions: it fails when the output does not match match or when it
+// matches noMatch. A nil expression is not checked. exceptionStep is the
+// special case of a matchStep failing on any output
+type matchStep struct {
+	step
+	match   *regexp.Regexp
+	noMatch *regexp.Regexp
+}
+
+func newMatchStep(name, exe, message, proj string, args []string, match, noMatch *regexp.Regexp) matchStep {
+	s := matchStep{}
+	s.step = newStep(name, exe, message, proj, args)
+	s.match = match
+	s.noMatch = noMatch
+	return s
+}
+
+func (s matchStep) execute(ctx context.Context) (stepResult, error) {
+	cmd := exec.CommandContext(ctx, s.exe, s.args...)
+
+	var out bytes.Buffer
+
+	output, err := s.run(ctx, cmd, &out)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+	if err != nil {
+		return res, err
+	}
+
+	if s.match != nil && !s.match.Match(out.Bytes()) {
+		return res, &stepErr{
+			step:   s.name,
+			msg:    fmt.Sprintf("output does not match %q", s.match),
+			cause:  ErrOutputMatch,
+			output: output,
+		}
+	}
+
+	if s.noMatch != nil {
+		if m := s.noMatch.Find(out.Bytes()); m != nil {
+			return res, &stepErr{
+				step:   s.name,
+				msg:    fmt.Sprintf("output matches %q: %s", s.noMatch, s.secrets.mask(string(m))),
+				cause:  ErrOutputMatch,
+				output: output,
+			}
+		}
+	}
+
+	res.msg = s.message
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMatchStep(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		match   string
+		noMatch string
+		expErr  error
+	}{
+		{name: "match", match: `^testdata/tool\n$`},
+		{name: "noMatch", noMatch: `vendor`},
+		{name: "both", match: `tool`, noMatch: `vendor`},
+		{name: "failMatch", match: `^example\.com/`, expErr: ErrOutputMatch},
+		{name: "failNoMatch", noMatch: `testdata/\w+`, expErr: ErrOutputMatch},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newMatchStep("go list", "go", "Go List: SUCCESS", "./testdata/tool", []string{"list"},
+				compileOptional(tc.match), compileOptional(tc.noMatch))
+
+			res, err := s.execute(context.Background())
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if res.msg != "Go List: SUCCESS" {
+				t.Errorf("expected message %q. got %q.", "Go List: SUCCESS", res.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// moduleResult is the outcome of the pipeline of a single module
+type moduleResult struct {
+	rel string
+	rep *runReport
+	err error
+}
+
+// findModules returns the sorted directories under root holding a go.mod
+// file. Like the go tool, it ignores hidden directories, directories
+// starting with an underscore, testdata and vendor directories
+func findModules(root string) ([]string, error) {
+	var mods []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			name := d.Name()
+
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+				name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.Name() == "go.mod" {
+			mods = append(mods, filepath.Dir(path))
+		}
+
+		return nil
+	})
+
+	sort.Strings(mods)
+	return mods, err
+}
+
+// runModules runs the pipeline of every module under root, up to
+// cfg.moduleJobs at a time, and prints a matrix of the step results.
+// Side-effecting steps are skipped in the modules and, once all of them
+// pass, the side-effecting steps of the root pipeline run once from root.
+// The notifiers of the root pipeline are sent once for the whole run.
+// The error returned belongs to the first failed module in path order
+func runModules(ctx context.Context, root string, out io.Writer, cfg config) (err error) {
+	mods, err := findModules(root)
+	if err != nil {
+		return err
+	}
+
+	if len(mods) == 0 {
+		return fmt.Errorf("%w: no Go modules found in %s", ErrValidation, root)
+	}
+
+	def, err := loadPipeline(root)
+	if err != nil {
+		return err
+	}
+
+	rep := &runReport{Project: root, Start: time.Now()}
+
+	// The whole run, including the root side-effecting steps, is
+	// notified once with the notifiers of the root pipeline
+	defer func() {
+		rep.finish(err)
+		notifyAll(ctx, def.Notify, root, rep, err, out)
+
+		if cfg.report == "" {
+			return
+		}
+
+		if rErr := rep.save(cfg.report); rErr != nil && err == nil {
+			err = rErr
+		}
+	}()
+
+	modCfg := cfg
+	modCfg.noPush = true
+
+	jobs := cfg.moduleJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]moduleResult, len(mods))
+	sem := make(chan struct{}, jobs)
+
+	var wg sync.WaitGroup
+
+	for i, dir := range mods {
+		rel, rErr := filepath.Rel(root, dir)
+		if rErr != nil {
+			rel = dir
+		}
+		results[i].rel = filepath.ToSlash(rel)
+
+		sem <- struct{}{}
+
+		if ctx.Err() != nil {
+			<-sem
+			results[i].err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *moduleResult, dir string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			modOut := &syncWriter{w: newPrefixWriter(out, r.rel)}
+			r.rep, _, r.err = execPipeline(ctx, dir, modOut, modCfg)
+		}(&results[i], dir)
+	}
+
+	wg.Wait()
+
+	if err := printMatrix(out, results); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		rep.addSteps(r.rel+": ", r.rep)
+	}
+
+	for _, r := range results {
+		if r.err != nil {
+			return fmt.Errorf("module %s: %w", r.rel, r.err)
+		}
+	}
+
+	if cfg.noPush {
+		return nil
+	}
+
+	push := def.sideEffects()
+	if len(push.Steps) == 0 {
+		return nil
+	}
+
+	tasks := push.build(root, out, cfg)
+	pushRep := newRunReport(root, tasks)
+
+	err = runTasks(ctx, tasks, cfg.jobs, out, pushRep)
+	pushRep.finish(err)
+	rep.addSteps("", pushRep)
+
+	return err
+}
+
+// printMatrix writes a table with a row per module and a column per step
+// holding the step status, or - when the module has no such step
+func printMatrix(out io.Writer, results []moduleResult) error {
+	var steps []string
+	seen := make(map[string]bool)
+
+	for _, r := range results {
+		if r.rep == nil {
+			continue
+		}
+
+		for _, s := range r.rep.Steps {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				steps = append(steps, s.Name)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "MODULE\t%s\tRESULT\n", strings.Join(steps, "\t"))
+
+	for _, r := range results {
+		status := make(map[string]string)
+		result := statusFailed
+
+		if r.rep != nil {
+			for _, s := range r.rep.Steps {
+				status[s.Name] = s.Status
+			}
+			result = r.rep.Status
+		}
+
+		row := []string{r.rel}
+		for _, name := range steps {
+			row = append(row, firstNonEmpty(status[name], "-"))
+		}
+		row = append(row, result)
+
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
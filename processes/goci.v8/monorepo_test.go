@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFindModules(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{".", "a", "b/c", ".hidden", "_old", "a/testdata/mod", "vendor/dep"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(root, dir, "go.mod"), []byte("module m\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mods, err := findModules(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{root, filepath.Join(root, "a"), filepath.Join(root, "b", "c")}
+	if !reflect.DeepEqual(mods, exp) {
+		t.Errorf("expected modules %q. got %q.", exp, mods)
+	}
+}
+
+func TestRunModules(t *testing.T) {
+	root := t.TempDir()
+	for _, mod := range []string{"tool", "toolFmtErr", "toolPipeline", "toolerr"} {
+		copyModule(t, filepath.Join("testdata", mod), filepath.Join(root, mod))
+	}
+
+	var out bytes.Buffer
+	err := run(root, &out, config{jobs: 4, noPush: true, modules: true, moduleJobs: 2})
+
+	if !errors.Is(err, &stepErr{step: "go fmt"}) {
+		t.Fatalf("expected error for step %q. got %q.", "go fmt", err)
+	}
+
+	if !strings.Contains(err.Error(), "module toolFmtErr") {
+		t.Errorf("expected error for module %q. got %q.", "toolFmtErr", err)
+	}
+
+	for _, exp := range []string{
+		"[tool] Go Build: SUCCESS\n",
+		"[toolPipeline] Go Vet: SUCCESS\n",
+	} {
+		if !strings.Contains(out.String(), exp) {
+			t.Errorf("expected output to contain %q. got %q.", exp, out.String())
+		}
+	}
+
+	expRows := [][]string{
+		{"MODULE", "go build", "go test", "go fmt", "git push", "go vet", "RESULT"},
+		{"tool", "success", "success", "success", "skipped", "-", "success"},
+		{"toolFmtErr", "success", "success", "failed", "skipped", "-", "failed"},
+		{"toolPipeline", "-", "success", "-", "-", "success", "success"},
+		{"toolerr", "failed", "skipped", "success", "skipped", "-", "failed"},
+	}
+
+	matrix := out.String()[strings.Index(out.String(), "MODULE"):]
+	lines := strings.Split(strings.TrimSpace(matrix), "\n")
+
+	if len(lines) != len(expRows) {
+		t.Fatalf("expected %d matrix rows. got %q.", len(expRows), matrix)
+	}
+
+	for i, line := range lines {
+		// Columns are separated by at least two spaces
+		if got := regexp.MustCompile(`\s{2,}`).Split(line, -1); !reflect.DeepEqual(got, expRows[i]) {
+			t.Errorf("expected matrix row %q. got %q.", expRows[i], line)
+		}
+	}
+}
+
+func TestRunModulesPushOnce(t *testing.T) {
+	root := t.TempDir()
+
+	for _, mod := range []string{"a", "b"} {
+		copyModule(t, filepath.Join("testdata", "tool"), filepath.Join(root, mod))
+	}
+
+	var tracker cmdTracker
+	command = tracker.track(mockCmdContext)
+	defer func() { command = exec.CommandContext }()
+
+	var out bytes.Buffer
+	if err := run(root, &out, config{jobs: 4, modules: true}); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+
+	if n := strings.Count(out.String(), "Git Push: SUCCESS\n"); n != 1 {
+		t.Errorf("expected a single push. got %d in %q.", n, out.String())
+	}
+
+	if !strings.HasSuffix(out.String(), "\nGit Push: SUCCESS\n") {
+		t.Errorf("expected push after the module matrix. got %q.", out.String())
+	}
+
+	pushes := 0
+	for _, cmd := range tracker.cmds {
+		if len(cmd.Args) > 3 && cmd.Args[3] == "push" {
+			pushes++
+
+			if cmd.Dir != root {
+				t.Errorf("expected push to run in %q. got %q.", root, cmd.Dir)
+			}
+		}
+	}
+
+	if pushes != 1 {
+		t.Errorf("expected git push to run once. got %d.", pushes)
+	}
+}
+
+// copyModule copies the files of the module in src to dst
+func copyModule(t *testing.T, src, dst string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dst, e.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	notifyDesktop = "desktop"
+	notifyWebhook = "webhook"
+	notifyLog     = "log"
+
+	notifyOnSuccess = "success"
+	notifyOnFailure = "failure"
+	notifyOnAlways  = "always"
+)
+
+// notifyTimeout bounds the time a notifier may take
+var notifyTimeout = 10 * time.Second
+
+// notifyDef selects a notifier sent when a run completes: a desktop
+// notification, a POST of the JSON run report to URL or a line appended
+// to File, relative to the project directory. On is one of success,
+// failure or always, the default
+type notifyDef struct {
+	Type string `yaml:"type" json:"type"`
+	On   string `yaml:"on" json:"on"`
+	URL  string `yaml:"url" json:"url"`
+	File string `yaml:"file" json:"file"`
+}
+
+// validate checks the notifier definition
+func (n notifyDef) validate() error {
+	switch n.On {
+	case "", notifyOnSuccess, notifyOnFailure, notifyOnAlways:
+	default:
+		return fmt.Errorf("invalid on %q", n.On)
+	}
+
+	switch n.Type {
+	case notifyDesktop:
+	case notifyWebhook:
+		u, err := url.Parse(n.URL)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid url %q", n.URL)
+		}
+	case notifyLog:
+		if n.File == "" {
+			return fmt.Errorf("missing file")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", n.Type)
+	}
+
+	return nil
+}
+
+// wants reports whether the notifier is sent for a run ending with status
+func (n notifyDef) wants(status string) bool {
+	switch n.On {
+	case notifyOnSuccess:
+		return status == statusSuccess
+	case notifyOnFailure:
+		return status == statusFailed
+	}
+
+	return status == statusSuccess || status == statusFailed
+}
+
+// notifier sends a notification about a completed run
+type notifier interface {
+	notify(ctx context.Context, rep *runReport, err error) error
+}
+
+// notifier creates the notifier described by n for project proj
+func (n notifyDef) notifier(proj string) notifier {
+	switch n.Type {
+	case notifyWebhook:
+		return webhookNotifier{url: n.URL}
+	case notifyLog:
+		return logNotifier{file: filepath.Join(proj, n.File)}
+	}
+
+	return desktopNotifier{}
+}
+
+// notifyAll sends the notifiers of defs wanting the outcome of rep.
+// Interrupted runs are not notified. Notifier failures do not change the
+// outcome of the run, they are reported to out
+func notifyAll(ctx context.Context, defs []notifyDef, proj string, rep *runReport, err error, out io.Writer) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
+	for _, n := range defs {
+		if !n.wants(rep.Status) {
+			continue
+		}
+
+		if nErr := n.notifier(proj).notify(ctx, rep, err); nErr != nil {
+			fmt.Fprintf(out, "notify %s: %v\n", n.Type, nErr)
+		}
+	}
+}
+
+// summary returns a one line outcome of the run ending with err
+func summary(rep *runReport, err error) string {
+	s := fmt.Sprintf("%s %s in %.1fs", rep.Project, rep.Status, rep.Duration)
+	if err != nil {
+		s += ": " + err.Error()
+	}
+
+	return s
+}
+
+// desktopNotifier shows a desktop notification with notify-send, when
+// it is installed
+type desktopNotifier struct{}
+
+func (desktopNotifier) notify(ctx context.Context, rep *runReport, err error) error {
+	exe, lErr := exec.LookPath("notify-send")
+	if lErr != nil {
+		return nil
+	}
+
+	title := "goci: PASS"
+	if err != nil {
+		title = "goci: FAIL"
+	}
+
+	return command(ctx, exe, title, summary(rep, err)).Run()
+}
+
+// webhookNotifier posts the JSON run report to url
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) notify(ctx context.Context, rep *runReport, err error) error {
+	data, jErr := rep.json()
+	if jErr != nil {
+		return jErr
+	}
+
+	req, rErr := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if rErr != nil {
+		return rErr
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, rErr := http.DefaultClient.Do(req)
+	if rErr != nil {
+		return rErr
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// logNotifier appends a line with the run outcome to file
+type logNotifier struct {
+	file string
+}
+
+func (l logNotifier) notify(ctx context.Context, rep *runReport, err error) error {
+	if mErr := os.MkdirAll(filepath.Dir(l.file), 0755); mErr != nil {
+		return mErr
+	}
+
+	f, oErr := os.OpenFile(l.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if oErr != nil {
+		return oErr
+	}
+
+	if _, wErr := fmt.Fprintf(f, "%s %s\n", rep.Start.Format(time.RFC3339), summary(rep, err)); wErr != nil {
+		f.Close()
+		return wErr
+	}
+
+	return f.Close()
+}
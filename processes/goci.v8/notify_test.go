@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed. Skipping test")
+	}
+
+	// Stand in for notify-send recording its arguments
+	bin := t.TempDir()
+	desktopLog := filepath.Join(bin, "desktop.log")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %q\n", desktopLog)
+	if err := os.WriteFile(filepath.Join(bin, "notify-send"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var testCases = []struct {
+		name       string
+		exit       int
+		hookStatus int
+		expErr     error
+		expStatus  string
+		expWebhook bool
+		expDesktop string
+		expOut     string
+	}{
+		{name: "success", exit: 0, hookStatus: http.StatusOK, expStatus: statusSuccess, expDesktop: "goci: PASS"},
+		{name: "failure", exit: 1, hookStatus: http.StatusOK, expErr: &stepErr{step: "check"}, expStatus: statusFailed, expWebhook: true},
+		{name: "webhookError", exit: 1, hookStatus: http.StatusInternalServerError, expErr: &stepErr{step: "check"}, expStatus: statusFailed, expWebhook: true, expOut: "notify webhook: unexpected status 500"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(desktopLog)
+
+			var mu sync.Mutex
+			var posts [][]byte
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				mu.Lock()
+				posts = append(posts, body)
+				mu.Unlock()
+
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("expected JSON content type. got %q.", r.Header.Get("Content-Type"))
+				}
+
+				w.WriteHeader(tc.hookStatus)
+			}))
+			defer srv.Close()
+
+			proj := t.TempDir()
+
+			pipeline := fmt.Sprintf(`steps:
+  - name: check
+    exe: sh
+    args: ["-c", "exit %d"]
+notify:
+  - type: webhook
+    url: %s
+    on: failure
+  - type: log
+    file: .goci/notify.log
+  - type: desktop
+    on: success
+`, tc.exit, srv.URL)
+
+			if err := os.WriteFile(filepath.Join(proj, ".goci.yaml"), []byte(pipeline), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			err := run(proj, &out, config{jobs: 1})
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if tc.expWebhook {
+				if len(posts) != 1 {
+					t.Fatalf("expected 1 webhook call. got %d.", len(posts))
+				}
+
+				var rep runReport
+				if err := json.Unmarshal(posts[0], &rep); err != nil {
+					t.Fatal(err)
+				}
+
+				if rep.Status != tc.expStatus || len(rep.Steps) != 1 {
+					t.Errorf("expected run report with status %q and 1 step. got %q and %d.", tc.expStatus, rep.Status, len(rep.Steps))
+				}
+			} else if len(posts) != 0 {
+				t.Errorf("expected no webhook call. got %d.", len(posts))
+			}
+
+			data, err := os.ReadFile(filepath.Join(proj, ".goci", "notify.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], proj+" "+tc.expStatus+" in ") {
+				t.Errorf("expected 1 log line with status %q. got %q.", tc.expStatus, data)
+			}
+
+			desktop, _ := os.ReadFile(desktopLog)
+			if tc.expDesktop == "" && len(desktop) > 0 || !strings.HasPrefix(string(desktop), tc.expDesktop) {
+				t.Errorf("expected desktop notification %q. got %q.", tc.expDesktop, desktop)
+			}
+
+			if !strings.Contains(out.String(), tc.expOut) {
+				t.Errorf("expected output to contain %q. got %q.", tc.expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestNotifyModules(t *testing.T) {
+	root := t.TempDir()
+	for _, mod := range []string{"a", "b"} {
+		copyModule(t, filepath.Join("testdata", "tool"), filepath.Join(root, mod))
+
+		// Module notifiers are not sent in monorepo mode
+		pipeline := "steps:\n  - name: vet\n    exe: go\n    args: [vet, ./...]\nnotify:\n  - type: log\n    file: notify.log\n"
+		if err := os.WriteFile(filepath.Join(root, mod, ".goci.yaml"), []byte(pipeline), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pipeline := "steps:\n  - name: push\n    exe: git\n    args: [push]\n    sideEffect: true\nnotify:\n  - type: log\n    file: .goci/notify.log\n"
+	if err := os.WriteFile(filepath.Join(root, ".goci.yaml"), []byte(pipeline), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(root, io.Discard, config{jobs: 2, noPush: true, modules: true, moduleJobs: 2}); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, ".goci", "notify.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], root+" "+statusSuccess+" in ") {
+		t.Errorf("expected 1 log line with status %q. got %q.", statusSuccess, data)
+	}
+
+	for _, mod := range []string{"a", "b"} {
+		if _, err := os.Stat(filepath.Join(root, mod, "notify.log")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected no notification from module %s. got %v.", mod, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxOutput is the number of bytes of output kept for each step
+const maxOutput = 64 * 1024
+
+// outputBuffer keeps the last max bytes written to it
+type outputBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	max       int
+	truncated int
+}
+
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{max: max}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+		b.truncated += over
+	}
+
+	return len(p), nil
+}
+
+// String returns the output kept, noting how much was discarded
+func (b *outputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated > 0 {
+		return fmt.Sprintf("... %d bytes truncated ...\n%s", b.truncated, b.buf)
+	}
+
+	return string(b.buf)
+}
+
+// syncWriter serializes writes from concurrent steps to w
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
+
+// prefixWriter writes every line it receives to w prefixed with the
+// step name, so live output of concurrent steps can be told apart
+type prefixWriter struct {
+	w       io.Writer
+	prefix  []byte
+	midLine bool
+}
+
+func newPrefixWriter(w io.Writer, name string) *prefixWriter {
+	return &prefixWriter{
+		w:      w,
+		prefix: []byte(fmt.Sprintf("[%s] ", name)),
+	}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var buf bytes.Buffer
+
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		if !p.midLine {
+			buf.Write(p.prefix)
+		}
+		buf.Write(line)
+
+		p.midLine = line[len(line)-1] != '\n'
+	}
+
+	// Write every line at once so they are not interleaved with
+	// lines of other steps
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
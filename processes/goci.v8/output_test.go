@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	b := newOutputBuffer(8)
+
+	fmt.Fprint(b, "1234")
+	if b.String() != "1234" {
+		t.Errorf("expected output: %q. got %q.", "1234", b.String())
+	}
+
+	fmt.Fprint(b, "56789abc")
+
+	exp := "... 4 bytes truncated ...\n56789abc"
+	if b.String() != exp {
+		t.Errorf("expected output: %q. got %q.", exp, b.String())
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newPrefixWriter(&out, "go test")
+
+	fmt.Fprint(w, "=== RUN TestAdd\n--- PASS")
+	fmt.Fprint(w, ": TestAdd\nok\n")
+
+	exp := "[go test] === RUN TestAdd\n[go test] --- PASS: TestAdd\n[go test] ok\n"
+	if out.String() != exp {
+		t.Errorf("expected output: %q. got %q.", exp, out.String())
+	}
+}
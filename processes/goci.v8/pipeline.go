@@ -0,0 +1,806 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kindStep      = "step"
+	kindException = "exceptionStep"
+	kindTimeout   = "timeoutStep"
+	kindCoverage  = "coverageStep"
+	kindVet       = "vetStep"
+	kindMatch     = "matchStep"
+	kindTest      = "testStep"
+)
+
+// pipelineFiles lists the pipeline definition files goci looks for in the
+// project directory, in order of preference
+var pipelineFiles = []string{".goci.yaml", ".goci.yml", ".goci.json"}
+
+// stepDef describes a single step in a pipeline definition file
+type stepDef struct {
+	Name    string    `yaml:"name" json:"name"`
+	Exe     string    `yaml:"exe" json:"exe"`
+	Args    []string  `yaml:"args" json:"args"`
+	Message string    `yaml:"message" json:"message"`
+	Kind    string    `yaml:"kind" json:"kind"`
+	Timeout string    `yaml:"timeout" json:"timeout"`
+	Needs   []string  `yaml:"needs" json:"needs"`
+	Retry   *retryDef `yaml:"retry" json:"retry"`
+
+	// Threshold is the minimum coverage percentage of a coverageStep
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+
+	// Match and NoMatch are the regular expressions the output of a
+	// matchStep must and must not match
+	Match   string `yaml:"match" json:"match"`
+	NoMatch string `yaml:"noMatch" json:"noMatch"`
+
+	// Slowest is the number of slowest tests a testStep reports, 5 when
+	// zero
+	Slowest int `yaml:"slowest" json:"slowest"`
+
+	// Limits restrict the resources used by the step commands
+	Limits *limitsDef `yaml:"limits" json:"limits"`
+
+	// Fix holds the arguments of the same exe fixing the files listed
+	// by a failed exceptionStep, which are appended to them
+	Fix []string `yaml:"fix" json:"fix"`
+
+	// SideEffect marks steps that change state outside the project,
+	// such as git push. They are skipped in no-push mode
+	SideEffect bool `yaml:"sideEffect" json:"sideEffect"`
+
+	// Env adds variables to the step environment, Secrets adds the
+	// variables of the same name in the secrets file and masks their
+	// values in the step output. Dir is the subdirectory of the project
+	// the step runs in
+	Env     map[string]string `yaml:"env" json:"env"`
+	Secrets []string          `yaml:"secrets" json:"secrets"`
+	Dir     string            `yaml:"dir" json:"dir"`
+
+	// If restricts when the step runs
+	If *conditionDef `yaml:"if" json:"if"`
+
+	// Inputs makes the step cacheable: it is skipped while its
+	// definition, command, Go toolchain and inputs match those of a
+	// previous successful run
+	Inputs *inputsDef `yaml:"inputs" json:"inputs"`
+}
+
+// inputsDef lists the file globs, relative to the project directory, and
+// the environment variables a step result depends on
+type inputsDef struct {
+	Files []string `yaml:"files" json:"files"`
+	Env   []string `yaml:"env" json:"env"`
+}
+
+// limitsDef sets the CPU time, as a duration, and the memory, as a size
+// such as 512MiB, each command of a step may use. Memory limits the
+// virtual address space (RLIMIT_AS), not the resident set size, which Go
+// programs and toolchains reserve generously: a few GiB are needed to
+// run go build or go test
+type limitsDef struct {
+	CPU    string `yaml:"cpu" json:"cpu"`
+	Memory string `yaml:"memory" json:"memory"`
+}
+
+// conditionDef restricts a step to a branch, given as a glob, to changes
+// since the previous commit of files matching the Changed globs, or to
+// runs where any of the Failed steps failed. Every field set must hold
+type conditionDef struct {
+	Branch  string   `yaml:"branch" json:"branch"`
+	Changed []string `yaml:"changed" json:"changed"`
+	Failed  []string `yaml:"failed" json:"failed"`
+}
+
+// goInputs are the inputs of the built-in Go steps
+var goInputs = &inputsDef{
+	Files: []string{"**/*.go", "go.mod", "go.sum", "**/testdata/**"},
+	Env:   []string{"GOFLAGS", "GOOS", "GOARCH", "CGO_ENABLED"},
+}
+
+// retryDef describes how a failed step is retried
+type retryDef struct {
+	Attempts int    `yaml:"attempts" json:"attempts"`
+	Backoff  string `yaml:"backoff" json:"backoff"`
+	Delay    string `yaml:"delay" json:"delay"`
+	On       string `yaml:"on" json:"on"`
+}
+
+// defaultRetryDelay is the wait before the first retry when none is set
+const defaultRetryDelay = time.Second
+
+// pipelineDef is the declarative form of a goci pipeline. Remote and
+// Branch fill the {remote} and {branch} placeholders in the arguments of
+// side-effecting steps. SecretsFile is the file, relative to the project
+// directory, holding the secrets steps refer to. Budget is the time the
+// whole run may take. Finally steps run after all other steps whether
+// the run succeeded, failed or was interrupted. Notify lists the
+// notifiers sent once the run completes
+type pipelineDef struct {
+	Remote      string      `yaml:"remote" json:"remote"`
+	Branch      string      `yaml:"branch" json:"branch"`
+	SecretsFile string      `yaml:"secretsFile" json:"secretsFile"`
+	Budget      string      `yaml:"budget" json:"budget"`
+	Steps       []stepDef   `yaml:"steps" json:"steps"`
+	Finally     []stepDef   `yaml:"finally" json:"finally"`
+	Notify      []notifyDef `yaml:"notify" json:"notify"`
+
+	// secrets holds the values read from SecretsFile
+	secrets map[string]string
+}
+
+// defaultPipeline returns the built-in pipeline used when the project
+// does not provide a pipeline definition file
+func defaultPipeline() pipelineDef {
+	return pipelineDef{
+		Steps: []stepDef{
+			{
+				Name:    "go build",
+				Exe:     "go",
+				Args:    []string{"build", ".", "errors"},
+				Message: "Go Build: SUCCESS",
+				Kind:    kindStep,
+				Inputs:  goInputs,
+			},
+			{
+				Name:    "go test",
+				Exe:     "go",
+				Args:    []string{"test", "-v"},
+				Message: "Go Test: SUCCESS",
+				Kind:    kindStep,
+				Needs:   []string{"go build"},
+				Inputs:  goInputs,
+			},
+			{
+				Name:    "go fmt",
+				Exe:     "gofmt",
+				Args:    []string{"-l", "."},
+				Message: "Gofmt: SUCCESS",
+				Kind:    kindException,
+				Fix:     []string{"-w"},
+				Inputs:  &inputsDef{Files: []string{"**/*.go"}},
+			},
+			{
+				Name:       "git push",
+				Exe:        "git",
+				Args:       []string{"push", "{remote}", "{branch}"},
+				Message:    "Git Push: SUCCESS",
+				Kind:       kindTimeout,
+				Timeout:    "10s",
+				Needs:      []string{"go build", "go test", "go fmt"},
+				SideEffect: true,
+			},
+		},
+	}
+}
+
+// loadPipeline reads the pipeline definition from the project directory,
+// falling back to the built-in pipeline when no file is present
+func loadPipeline(proj string) (pipelineDef, error) {
+	for _, name := range pipelineFiles {
+		path := filepath.Join(proj, name)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return pipelineDef{}, err
+		}
+
+		p, err := parsePipeline(path, data)
+		if err != nil {
+			return pipelineDef{}, err
+		}
+
+		if err := p.validate(); err != nil {
+			return pipelineDef{}, err
+		}
+
+		return p, p.loadSecrets(proj)
+	}
+
+	return defaultPipeline(), nil
+}
+
+// loadSecrets reads the secrets file when a step needs secrets, checking
+// that every secret is defined
+func (p *pipelineDef) loadSecrets(proj string) error {
+	for _, s := range p.allSteps() {
+		for _, name := range s.Secrets {
+			if p.secrets == nil {
+				path := filepath.Join(proj, firstNonEmpty(p.SecretsFile, defaultSecretsFile))
+
+				secrets, err := loadSecrets(path)
+				if err != nil {
+					return fmt.Errorf("%w: secrets: %v", ErrValidation, err)
+				}
+				p.secrets = secrets
+			}
+
+			if _, ok := p.secrets[name]; !ok {
+				return &stepErr{
+					step:  s.Name,
+					msg:   fmt.Sprintf("secret %q not found", name),
+					cause: ErrValidation,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// parsePipeline decodes a pipeline definition as YAML or JSON depending
+// on the file extension. Unknown fields are rejected
+func parsePipeline(path string, data []byte) (pipelineDef, error) {
+	var p pipelineDef
+	var err error
+
+	switch filepath.Ext(path) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&p)
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&p)
+	}
+
+	if err != nil {
+		return pipelineDef{}, fmt.Errorf("%w: %s: %v", ErrValidation, path, err)
+	}
+
+	return p, nil
+}
+
+// validate checks every step definition and reports the first offending step
+func (p pipelineDef) validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("%w: pipeline has no steps", ErrValidation)
+	}
+
+	if p.Budget != "" {
+		if d, err := time.ParseDuration(p.Budget); err != nil || d <= 0 {
+			return fmt.Errorf("%w: invalid budget %q", ErrValidation, p.Budget)
+		}
+	}
+
+	for i, n := range p.Notify {
+		if err := n.validate(); err != nil {
+			return fmt.Errorf("%w: notify %d: %v", ErrValidation, i+1, err)
+		}
+	}
+
+	all := p.allSteps()
+	names := make(map[string]bool, len(all))
+
+	for i, s := range all {
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		invalid := func(format string, a ...any) error {
+			return &stepErr{
+				step:  name,
+				msg:   fmt.Sprintf(format, a...),
+				cause: ErrValidation,
+			}
+		}
+
+		switch {
+		case s.Name == "":
+			return invalid("missing name")
+		case names[s.Name]:
+			return invalid("duplicate name")
+		case s.Exe == "":
+			return invalid("missing exe")
+		}
+		names[s.Name] = true
+
+		switch s.Kind {
+		case "", kindStep, kindException, kindCoverage, kindVet, kindMatch, kindTest:
+			if s.Timeout != "" {
+				return invalid("timeout only valid for kind %q", kindTimeout)
+			}
+		case kindTimeout:
+			if s.Timeout == "" {
+				break
+			}
+
+			d, err := time.ParseDuration(s.Timeout)
+			if err != nil {
+				return invalid("invalid timeout %q", s.Timeout)
+			}
+
+			if d <= 0 {
+				return invalid("timeout must be positive")
+			}
+		default:
+			return invalid("unknown kind %q", s.Kind)
+		}
+
+		if s.Threshold != 0 && s.Kind != kindCoverage {
+			return invalid("threshold only valid for kind %q", kindCoverage)
+		}
+
+		if s.Threshold < 0 || s.Threshold > 100 {
+			return invalid("threshold must be between 0 and 100")
+		}
+
+		if s.Slowest != 0 && s.Kind != kindTest {
+			return invalid("slowest only valid for kind %q", kindTest)
+		}
+
+		if s.Slowest < 0 {
+			return invalid("slowest must not be negative")
+		}
+
+		if s.Kind == kindMatch {
+			if s.Match == "" && s.NoMatch == "" {
+				return invalid("match or noMatch required for kind %q", kindMatch)
+			}
+
+			for _, re := range []string{s.Match, s.NoMatch} {
+				if _, err := regexp.Compile(re); err != nil {
+					return invalid("invalid regular expression %q", re)
+				}
+			}
+		} else if s.Match != "" || s.NoMatch != "" {
+			return invalid("match and noMatch only valid for kind %q", kindMatch)
+		}
+
+		if l := s.Limits; l != nil {
+			if l.CPU != "" {
+				if d, err := time.ParseDuration(l.CPU); err != nil || d <= 0 {
+					return invalid("invalid cpu limit %q", l.CPU)
+				}
+			}
+
+			if l.Memory != "" {
+				if _, err := parseSize(l.Memory); err != nil {
+					return invalid("invalid memory limit %q", l.Memory)
+				}
+			}
+		}
+
+		if len(s.Fix) > 0 && s.Kind != kindException {
+			return invalid("fix only valid for kind %q", kindException)
+		}
+
+		if s.Dir != "" && !filepath.IsLocal(s.Dir) {
+			return invalid("dir %q must be a subdirectory of the project", s.Dir)
+		}
+
+		for k := range s.Env {
+			if k == "" || strings.ContainsAny(k, "= ") {
+				return invalid("invalid env variable %q", k)
+			}
+		}
+
+		for _, k := range s.Secrets {
+			if k == "" || strings.ContainsAny(k, "= ") {
+				return invalid("invalid secret variable %q", k)
+			}
+		}
+
+		if c := s.If; c != nil {
+			if _, err := path.Match(c.Branch, ""); err != nil {
+				return invalid("invalid branch pattern %q", c.Branch)
+			}
+
+			for _, f := range c.Changed {
+				if !validGlob(f) {
+					return invalid("invalid changed glob %q", f)
+				}
+			}
+		}
+
+		if r := s.Retry; r != nil {
+			if r.Attempts < 1 {
+				return invalid("retry attempts must be at least 1")
+			}
+
+			switch r.Backoff {
+			case "", backoffFixed, backoffExponential:
+			default:
+				return invalid("unknown retry backoff %q", r.Backoff)
+			}
+
+			switch r.On {
+			case "", retryOnAny, retryOnTimeout:
+			default:
+				return invalid("unknown retry condition %q", r.On)
+			}
+
+			if r.Delay != "" {
+				if d, err := time.ParseDuration(r.Delay); err != nil || d < 0 {
+					return invalid("invalid retry delay %q", r.Delay)
+				}
+			}
+		}
+
+		if in := s.Inputs; in != nil {
+			if s.SideEffect {
+				return invalid("side-effecting steps cannot be cached")
+			}
+
+			for _, f := range in.Files {
+				if !validGlob(f) {
+					return invalid("invalid input glob %q", f)
+				}
+			}
+		}
+	}
+
+	return p.validateNeeds(names)
+}
+
+// validateNeeds checks that every dependency, including the steps whose
+// failure triggers a step, names an existing step, that no step but a
+// finally step depends on a finally step and that the dependencies do
+// not form a cycle
+func (p pipelineDef) validateNeeds(names map[string]bool) error {
+	all := p.allSteps()
+	needs := make(map[string][]string, len(all))
+
+	finally := make(map[string]bool, len(p.Finally))
+	for _, s := range p.Finally {
+		finally[s.Name] = true
+	}
+
+	for _, s := range all {
+		deps := s.Needs
+		if s.If != nil {
+			deps = append(append([]string{}, deps...), s.If.Failed...)
+		}
+
+		for _, n := range deps {
+			var msg string
+
+			switch {
+			case !names[n]:
+				msg = fmt.Sprintf("unknown dependency %q", n)
+			case finally[n] && !finally[s.Name]:
+				msg = fmt.Sprintf("cannot depend on finally step %q", n)
+			default:
+				continue
+			}
+
+			return &stepErr{
+				step:  s.Name,
+				msg:   msg,
+				cause: ErrValidation,
+			}
+		}
+		needs[s.Name] = deps
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(all))
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch marks[name] {
+		case visiting:
+			return false
+		case visited:
+			return true
+		}
+
+		marks[name] = visiting
+		for _, n := range needs[name] {
+			if !visit(n) {
+				return false
+			}
+		}
+		marks[name] = visited
+
+		return true
+	}
+
+	for _, s := range all {
+		if !visit(s.Name) {
+			return &stepErr{
+				step:  s.Name,
+				msg:   "dependency cycle",
+				cause: ErrValidation,
+			}
+		}
+	}
+
+	return nil
+}
+
+// allSteps returns the steps of p followed by its finally steps
+func (p pipelineDef) allSteps() []stepDef {
+	return append(append([]stepDef{}, p.Steps...), p.Finally...)
+}
+
+// selectSteps returns the pipeline made of the named steps along with
+// the steps they depend on, either through needs or failure conditions.
+// Finally steps are always kept so cleanup still runs on a subset
+func (p pipelineDef) selectSteps(names []string) (pipelineDef, error) {
+	all := p.allSteps()
+
+	byName := make(map[string]stepDef, len(all))
+	for _, s := range all {
+		byName[s.Name] = s
+	}
+
+	selected := make(map[string]bool)
+
+	var add func(name string)
+	add = func(name string) {
+		if selected[name] {
+			return
+		}
+		selected[name] = true
+
+		s := byName[name]
+		for _, n := range s.Needs {
+			add(n)
+		}
+		if s.If != nil {
+			for _, n := range s.If.Failed {
+				add(n)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := byName[name]; !ok {
+			return pipelineDef{}, fmt.Errorf("%w: unknown step %q", ErrValidation, name)
+		}
+
+		add(name)
+	}
+
+	for _, s := range p.Finally {
+		add(s.Name)
+	}
+
+	sel := p
+	sel.Steps = nil
+
+	for _, s := range p.Steps {
+		if selected[s.Name] {
+			sel.Steps = append(sel.Steps, s)
+		}
+	}
+
+	return sel, nil
+}
+
+// sideEffects returns the pipeline made of the side-effecting steps of p
+// only, without their dependencies, so they can run on their own
+func (p pipelineDef) sideEffects() pipelineDef {
+	se := pipelineDef{Remote: p.Remote, Branch: p.Branch, secrets: p.secrets}
+
+	for _, s := range p.Steps {
+		if s.SideEffect {
+			s.Needs = nil
+			se.Steps = append(se.Steps, s)
+		}
+	}
+
+	return se
+}
+
+// build turns a validated pipeline definition into tasks ready to be
+// scheduled. Retry attempts are reported to out and, in verbose mode,
+// the output of every step is streamed to it prefixed with the step name
+func (p pipelineDef) build(proj string, out io.Writer, cfg config) []task {
+	all := p.allSteps()
+
+	index := make(map[string]int, len(all))
+	for i, s := range all {
+		index[s.Name] = i
+	}
+
+	remote := firstNonEmpty(cfg.remote, p.Remote, defaultRemote)
+	branch := firstNonEmpty(cfg.branch, p.Branch)
+
+	pipeline := make([]task, 0, len(all))
+
+	for i, s := range all {
+		t := task{name: s.Name, finally: i >= len(p.Steps)}
+
+		for _, n := range s.Needs {
+			t.needs = append(t.needs, index[n])
+		}
+
+		if c := s.If; c != nil {
+			for _, n := range c.Failed {
+				t.onFailure = append(t.onFailure, index[n])
+			}
+
+			if c.Branch != "" || len(c.Changed) > 0 {
+				t.cond = &condition{proj: proj, branch: c.Branch, changed: c.Changed}
+			}
+		}
+
+		newExec := func(args []string) executer {
+			c := cfg
+			if s.SideEffect {
+				// Side-effecting steps act outside the project
+				c.isolate = ""
+			}
+
+			return s.executer(proj, args, p.secrets, out, c)
+		}
+
+		switch {
+		case s.SideEffect && cfg.noPush:
+			t.skip = true
+			t.exec = newExec(expandArgs(s.Args, remote, firstNonEmpty(branch, "{branch}")))
+		case s.SideEffect:
+			t.exec = newSideEffectStep(s.Name, proj, s.Args, remote, branch, newExec)
+		default:
+			t.exec = newExec(s.Args)
+		}
+
+		if cfg.fix && len(s.Fix) > 0 {
+			t.exec = newFixStep(t.exec, s.Name, s.Exe, s.Fix, filepath.Join(proj, s.Dir), cfg.fixStage, out)
+		}
+
+		// Worktree runs check HEAD while the cache key hashes the
+		// working tree, so their results are not cached
+		if s.Inputs != nil && cfg.cacheDir != "" && !s.SideEffect && cfg.isolate != isolateWorktree {
+			// Every field of the definition may change the outcome
+			def, err := json.Marshal(s)
+			if err == nil {
+				t.cache = &stepCache{
+					dir:     cfg.cacheDir,
+					proj:    proj,
+					name:    s.Name,
+					command: t.exec.commandLine(),
+					def:     def,
+					files:   s.Inputs.Files,
+					env:     s.Inputs.Env,
+				}
+			}
+		}
+
+		pipeline = append(pipeline, t)
+	}
+
+	return pipeline
+}
+
+// executer creates the step described by s running with args. Its
+// secrets are looked up in secrets. Its output is streamed to out in
+// verbose mode and its commands run in a fresh copy of proj when cfg
+// sets an isolation mode
+func (s stepDef) executer(proj string, args []string, secrets map[string]string, out io.Writer, cfg config) executer {
+	var e executer
+
+	dir := filepath.Join(proj, s.Dir)
+
+	stream := io.Discard
+	if cfg.verbose {
+		stream = newPrefixWriter(out, s.Name)
+	}
+
+	var env, values []string
+	for k, v := range s.Env {
+		env = append(env, k+"="+v)
+	}
+	for _, k := range s.Secrets {
+		env = append(env, k+"="+secrets[k])
+		values = append(values, secrets[k])
+	}
+	sort.Strings(env)
+
+	// Limits were checked by validate
+	var lim limits
+	if l := s.Limits; l != nil {
+		lim.cpu, _ = time.ParseDuration(l.CPU)
+		lim.memory, _ = parseSize(l.Memory)
+	}
+
+	configure := func(st *step) {
+		st.stream = stream
+		st.env = env
+		st.secrets = newMasker(values)
+		st.limits = lim
+		st.root = proj
+		st.isolate = cfg.isolate
+		if cfg.gracePeriod > 0 {
+			st.grace = cfg.gracePeriod
+		}
+	}
+
+	switch s.Kind {
+	case kindException:
+		st := newExceptionStep(s.Name, s.Exe, s.Message, dir, args)
+		configure(&st.step)
+		e = st
+	case kindTimeout:
+		// Timeout was checked by validate, an empty value
+		// selects the timeoutStep default
+		timeout, _ := time.ParseDuration(s.Timeout)
+		st := newTimeoutStep(s.Name, s.Exe, s.Message, dir, args, timeout)
+		configure(&st.step)
+		e = st
+	case kindCoverage:
+		st := newCoverageStep(s.Name, s.Exe, s.Message, dir, args, s.Threshold)
+		configure(&st.step)
+		e = st
+	case kindVet:
+		st := newVetStep(s.Name, s.Exe, s.Message, dir, args)
+		configure(&st.step)
+		e = st
+	case kindMatch:
+		// Expressions were checked by validate
+		st := newMatchStep(s.Name, s.Exe, s.Message, dir, args, compileOptional(s.Match), compileOptional(s.NoMatch))
+		configure(&st.step)
+		e = st
+	case kindTest:
+		slowest := s.Slowest
+		if slowest == 0 {
+			slowest = defaultSlowest
+		}
+
+		st := newTestStep(s.Name, s.Exe, s.Message, dir, args, slowest)
+		configure(&st.step)
+		e = st
+	default:
+		st := newStep(s.Name, s.Exe, s.Message, dir, args)
+		configure(&st)
+		e = st
+	}
+
+	if r := s.Retry; r != nil && r.Attempts > 1 {
+		delay := defaultRetryDelay
+		if r.Delay != "" {
+			delay, _ = time.ParseDuration(r.Delay)
+		}
+
+		e = newRetryStep(e, s.Name, r.Attempts, delay, r.Backoff, r.On, out)
+	}
+
+	return e
+}
+
+// compileOptional compiles expr, returning nil when it is empty
+func compileOptional(expr string) *regexp.Regexp {
+	if expr == "" {
+		return nil
+	}
+
+	return regexp.MustCompile(expr)
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
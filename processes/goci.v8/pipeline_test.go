@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadPipeline(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		file     string
+		content  string
+		expSteps []string
+		expErr   error
+	}{
+		{name: "default", file: "", content: "", expSteps: []string{"go build", "go test", "go fmt", "git push"}, expErr: nil},
+		{name: "yaml", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    args: [vet]\n  - name: push\n    exe: git\n    kind: timeoutStep\n    timeout: 5s\n", expSteps: []string{"vet", "push"}, expErr: nil},
+		{name: "json", file: ".goci.json", content: `{"steps": [{"name": "fmt", "exe": "gofmt", "args": ["-l", "."], "kind": "exceptionStep"}]}`, expSteps: []string{"fmt"}, expErr: nil},
+		{name: "noSteps", file: ".goci.yaml", content: "steps: []\n", expErr: ErrValidation},
+		{name: "unknownField", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    command: vet\n", expErr: ErrValidation},
+		{name: "missingExe", file: ".goci.yaml", content: "steps:\n  - name: vet\n", expErr: &stepErr{step: "vet"}},
+		{name: "duplicateName", file: ".goci.json", content: `{"steps": [{"name": "a", "exe": "go"}, {"name": "a", "exe": "go"}]}`, expErr: &stepErr{step: "a"}},
+		{name: "unknownKind", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    kind: magicStep\n", expErr: &stepErr{step: "vet"}},
+		{name: "badTimeout", file: ".goci.yaml", content: "steps:\n  - name: push\n    exe: git\n    kind: timeoutStep\n    timeout: soon\n", expErr: &stepErr{step: "push"}},
+		{name: "unknownNeed", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    needs: [build]\n", expErr: &stepErr{step: "vet"}},
+		{name: "needsCycle", file: ".goci.yaml", content: "steps:\n  - name: a\n    exe: go\n    needs: [b]\n  - name: b\n    exe: go\n    needs: [a]\n", expErr: &stepErr{step: "a"}},
+		{name: "retry", file: ".goci.yaml", content: "steps:\n  - name: push\n    exe: git\n    retry:\n      attempts: 3\n      backoff: exponential\n      delay: 2s\n      on: timeout\n", expSteps: []string{"push"}, expErr: nil},
+		{name: "badRetryAttempts", file: ".goci.yaml", content: "steps:\n  - name: push\n    exe: git\n    retry:\n      attempts: 0\n", expErr: &stepErr{step: "push"}},
+		{name: "badRetryBackoff", file: ".goci.yaml", content: "steps:\n  - name: push\n    exe: git\n    retry:\n      attempts: 2\n      backoff: linear\n", expErr: &stepErr{step: "push"}},
+		{name: "badRetryOn", file: ".goci.yaml", content: "steps:\n  - name: push\n    exe: git\n    retry:\n      attempts: 2\n      on: never\n", expErr: &stepErr{step: "push"}},
+		{name: "badInputGlob", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    inputs:\n      files: [\"[*.go\"]\n", expErr: &stepErr{step: "vet"}},
+		{name: "cachedSideEffect", file: ".goci.yaml", content: "steps:\n  - name: push\n    exe: git\n    sideEffect: true\n    inputs:\n      files: [\"*.go\"]\n", expErr: &stepErr{step: "push"}},
+		{name: "stepKinds", file: ".goci.yaml", content: "steps:\n  - name: cover\n    exe: go\n    args: [test]\n    kind: coverageStep\n    threshold: 80\n  - name: vet\n    exe: go\n    args: [vet, ./...]\n    kind: vetStep\n  - name: todo\n    exe: grep\n    kind: matchStep\n    noMatch: TODO\n", expSteps: []string{"cover", "vet", "todo"}, expErr: nil},
+		{name: "thresholdWrongKind", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    threshold: 80\n", expErr: &stepErr{step: "test"}},
+		{name: "badThreshold", file: ".goci.yaml", content: "steps:\n  - name: cover\n    exe: go\n    kind: coverageStep\n    threshold: 120\n", expErr: &stepErr{step: "cover"}},
+		{name: "matchMissing", file: ".goci.yaml", content: "steps:\n  - name: todo\n    exe: grep\n    kind: matchStep\n", expErr: &stepErr{step: "todo"}},
+		{name: "badMatch", file: ".goci.yaml", content: "steps:\n  - name: todo\n    exe: grep\n    kind: matchStep\n    match: \"(\"\n", expErr: &stepErr{step: "todo"}},
+		{name: "matchWrongKind", file: ".goci.yaml", content: "steps:\n  - name: todo\n    exe: grep\n    match: TODO\n", expErr: &stepErr{step: "todo"}},
+		{name: "stepEnv", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    args: [test]\n    dir: cmd\n    env:\n      CGO_ENABLED: \"0\"\n", expSteps: []string{"test"}, expErr: nil},
+		{name: "dirOutsideProject", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    dir: ../other\n", expErr: &stepErr{step: "test"}},
+		{name: "badEnv", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    env:\n      \"A=B\": x\n", expErr: &stepErr{step: "test"}},
+		{name: "missingSecretsFile", file: ".goci.yaml", content: "steps:\n  - name: deploy\n    exe: sh\n    secrets: [TOKEN]\n", expErr: ErrValidation},
+		{name: "conditions", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    if:\n      branch: main\n      changed: [\"**/*.go\"]\n  - name: report\n    exe: sh\n    if:\n      failed: [test]\nfinally:\n  - name: clean\n    exe: go\n    args: [clean]\n", expSteps: []string{"test", "report", "clean"}, expErr: nil},
+		{name: "unknownFailed", file: ".goci.yaml", content: "steps:\n  - name: report\n    exe: sh\n    if:\n      failed: [test]\n", expErr: &stepErr{step: "report"}},
+		{name: "badBranchPattern", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    if:\n      branch: \"[main\"\n", expErr: &stepErr{step: "test"}},
+		{name: "needsFinally", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    needs: [clean]\nfinally:\n  - name: clean\n    exe: go\n", expErr: &stepErr{step: "test"}},
+		{name: "duplicateFinallyName", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\nfinally:\n  - name: test\n    exe: go\n", expErr: &stepErr{step: "test"}},
+		{name: "fixWrongKind", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    fix: [-w]\n", expErr: &stepErr{step: "vet"}},
+		{name: "limits", file: ".goci.yaml", content: "budget: 10m\nsteps:\n  - name: test\n    exe: go\n    limits:\n      cpu: 2m\n      memory: 1GiB\n", expSteps: []string{"test"}, expErr: nil},
+		{name: "badCPULimit", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    limits:\n      cpu: lots\n", expErr: &stepErr{step: "test"}},
+		{name: "badMemoryLimit", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    limits:\n      memory: 1TB\n", expErr: &stepErr{step: "test"}},
+		{name: "badBudget", file: ".goci.yaml", content: "budget: -1m\nsteps:\n  - name: test\n    exe: go\n", expErr: ErrValidation},
+		{name: "testStep", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    args: [test, ./...]\n    kind: testStep\n    slowest: 10\n", expSteps: []string{"test"}, expErr: nil},
+		{name: "slowestWrongKind", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    slowest: 10\n", expErr: &stepErr{step: "test"}},
+		{name: "badSlowest", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\n    kind: testStep\n    slowest: -1\n", expErr: &stepErr{step: "test"}},
+		{name: "notify", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\nnotify:\n  - type: desktop\n  - type: webhook\n    url: https://ci.example.com/hook\n    on: failure\n  - type: log\n    file: goci.log\n    on: success\n", expSteps: []string{"test"}, expErr: nil},
+		{name: "unknownNotifier", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\nnotify:\n  - type: email\n", expErr: ErrValidation},
+		{name: "badWebhookURL", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\nnotify:\n  - type: webhook\n    url: ci.example.com\n", expErr: ErrValidation},
+		{name: "badNotifyOn", file: ".goci.yaml", content: "steps:\n  - name: test\n    exe: go\nnotify:\n  - type: desktop\n    on: sometimes\n", expErr: ErrValidation},
+		{name: "timeoutWrongKind", file: ".goci.yaml", content: "steps:\n  - name: vet\n    exe: go\n    timeout: 5s\n", expErr: &stepErr{step: "vet"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proj := t.TempDir()
+
+			if tc.file != "" {
+				if err := os.WriteFile(filepath.Join(proj, tc.file), []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			p, err := loadPipeline(proj)
+
+			if tc.expErr != nil {
+				if err == nil {
+					t.Fatalf("expected error: %q. got nil.", tc.expErr)
+				}
+
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+
+				if !errors.Is(err, ErrValidation) {
+					t.Errorf("expected error: %q. got %q.", ErrValidation, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			steps := p.allSteps()
+
+			if len(steps) != len(tc.expSteps) {
+				t.Fatalf("expected %d steps. got %d.", len(tc.expSteps), len(steps))
+			}
+
+			for i, s := range steps {
+				if s.Name != tc.expSteps[i] {
+					t.Errorf("expected step %d to be %q. got %q.", i, tc.expSteps[i], s.Name)
+				}
+			}
+
+			if len(p.build(proj, io.Discard, config{})) != len(tc.expSteps) {
+				t.Errorf("expected %d executers", len(tc.expSteps))
+			}
+		})
+	}
+}
+
+func TestBuildPushTarget(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		def    pipelineDef
+		cfg    config
+		expCmd string
+		expRun bool
+	}{
+		{name: "default", def: defaultPipeline(), cfg: config{}, expCmd: "git push origin {branch}", expRun: true},
+		{name: "flags", def: defaultPipeline(), cfg: config{remote: "upstream", branch: "main"}, expCmd: "git push upstream main", expRun: true},
+		{name: "file", def: pipelineDef{Remote: "fork", Branch: "dev", Steps: defaultPipeline().Steps}, cfg: config{branch: "main"}, expCmd: "git push fork main", expRun: true},
+		{name: "noPush", def: defaultPipeline(), cfg: config{branch: "main", noPush: true}, expCmd: "git push origin main", expRun: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tasks := tc.def.build(".", io.Discard, tc.cfg)
+			push := tasks[len(tasks)-1]
+
+			if cmd := push.exec.commandLine(); cmd != tc.expCmd {
+				t.Errorf("expected command %q. got %q.", tc.expCmd, cmd)
+			}
+
+			if push.skip == tc.expRun {
+				t.Errorf("expected step to run: %t. got %t.", tc.expRun, !push.skip)
+			}
+		})
+	}
+}
+
+func TestSelectSteps(t *testing.T) {
+	def := defaultPipeline()
+	def.Steps = append(def.Steps, stepDef{Name: "report", Exe: "sh", If: &conditionDef{Failed: []string{"go vet"}}})
+	def.Steps = append(def.Steps, stepDef{Name: "go vet", Exe: "go"})
+	def.Finally = []stepDef{{Name: "clean", Exe: "go", Needs: []string{"go build"}}}
+
+	var testCases = []struct {
+		name     string
+		steps    []string
+		expSteps []string
+		expErr   error
+	}{
+		{name: "single", steps: []string{"go fmt"}, expSteps: []string{"go build", "go fmt", "clean"}},
+		{name: "withNeeds", steps: []string{"go test", "go fmt"}, expSteps: []string{"go build", "go test", "go fmt", "clean"}},
+		{name: "transitive", steps: []string{"git push"}, expSteps: []string{"go build", "go test", "go fmt", "git push", "clean"}},
+		{name: "failureCondition", steps: []string{"report"}, expSteps: []string{"go build", "report", "go vet", "clean"}},
+		{name: "finally", steps: []string{"clean"}, expSteps: []string{"go build", "clean"}},
+		{name: "unknown", steps: []string{"go bild"}, expErr: ErrValidation},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sel, err := def.selectSteps(tc.steps)
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			var names []string
+			for _, s := range sel.allSteps() {
+				names = append(names, s.Name)
+			}
+
+			if !reflect.DeepEqual(names, tc.expSteps) {
+				t.Errorf("expected steps %q. got %q.", tc.expSteps, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// newProcessGroup does nothing, process groups are only supported on Unix
+func newProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends sig to p alone
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	return p.Signal(sig)
+}
+
+// waitGroup does nothing, the processes started by p are not tracked
+func waitGroup(p *os.Process, deadline time.Time) {}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// newProcessGroup makes cmd start in a process group of its own, so the
+// processes it starts can be signalled along with it
+func newProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends sig to the process group led by p
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	return syscall.Kill(-p.Pid, sig)
+}
+
+// waitGroup waits for the processes left in the process group led by p
+// to exit, killing them once deadline has passed
+func waitGroup(p *os.Process, deadline time.Time) {
+	for syscall.Kill(-p.Pid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(-p.Pid, syscall.SIGKILL)
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
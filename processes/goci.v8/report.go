@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	statusSuccess   = "success"
+	statusFailed    = "failed"
+	statusTimeout   = "timeout"
+	statusSkipped   = "skipped"
+	statusSignalled = "signalled"
+	statusCached    = "cached"
+	statusFixed     = "fixed"
+)
+
+// stepReport holds the outcome of a single step
+type stepReport struct {
+	Name     string    `json:"name"`
+	Command  string    `json:"command"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	ExitCode int       `json:"exitCode"`
+	Status   string    `json:"status"`
+	Attempts int       `json:"attempts,omitempty"`
+	Retries  []string  `json:"retries,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Output   string    `json:"output,omitempty"`
+	Findings []finding `json:"findings,omitempty"`
+
+	// Resources used by the step commands: peak resident set size in
+	// bytes and CPU times in seconds
+	MaxRSS     int64   `json:"maxRSS,omitempty"`
+	UserTime   float64 `json:"userTime,omitempty"`
+	SystemTime float64 `json:"systemTime,omitempty"`
+
+	// Tests is the outcome of the tests run by a testStep
+	Tests *testSummary `json:"tests,omitempty"`
+}
+
+// runReport records the outcome of a pipeline run. It is safe for
+// concurrent use
+type runReport struct {
+	mu       sync.Mutex
+	Project  string       `json:"project"`
+	Start    time.Time    `json:"start"`
+	Duration float64      `json:"duration"`
+	Status   string       `json:"status"`
+	Steps    []stepReport `json:"steps"`
+}
+
+func newRunReport(proj string, tasks []task) *runReport {
+	r := &runReport{
+		Project: proj,
+		Start:   time.Now(),
+		Steps:   make([]stepReport, len(tasks)),
+	}
+
+	for i, t := range tasks {
+		r.Steps[i] = stepReport{
+			Name:     t.name,
+			Command:  t.exec.commandLine(),
+			ExitCode: -1,
+		}
+	}
+
+	return r
+}
+
+// stepStarted records the start time of step i
+func (r *runReport) stepStarted(i int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Start = time.Now()
+}
+
+// stepFinished records the outcome of step i based on the error it returned
+func (r *runReport) stepFinished(i int, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &r.Steps[i]
+	s.Duration = time.Since(s.Start).Seconds()
+	s.Status = stepStatus(err)
+	s.ExitCode = 0
+
+	if err == nil {
+		return
+	}
+
+	s.Error = err.Error()
+
+	var sErr *stepErr
+	if errors.As(err, &sErr) {
+		s.ExitCode = sErr.exitCode
+		s.Output = sErr.output
+		s.Attempts = sErr.attempts
+		s.Findings = sErr.findings
+	}
+}
+
+// stepUsage records the resources used by step i
+func (r *runReport) stepUsage(i int, u *usage) {
+	if r == nil || u == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &r.Steps[i]
+	s.MaxRSS = u.maxRSS
+	s.UserTime = u.userTime.Seconds()
+	s.SystemTime = u.systemTime.Seconds()
+}
+
+// stepAttempts records the attempts taken by step i when it was retried,
+// along with the errors of the failed ones
+func (r *runReport) stepAttempts(i int, l *attemptLog) {
+	if r == nil || l == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Attempts = l.attempts
+	r.Steps[i].Retries = l.errors
+}
+
+// stepTests records the outcome of the tests run by step i, if any
+func (r *runReport) stepTests(i int, sum *testSummary) {
+	if r == nil || sum == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Tests = sum
+}
+
+// stepCached records that step i was skipped because its inputs did
+// not change since its last successful run
+func (r *runReport) stepCached(i int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Status = statusCached
+}
+
+// stepFixed records that step i found issues and fixed them with diff
+func (r *runReport) stepFixed(i int, diff string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Status = statusFixed
+	r.Steps[i].Output = diff
+}
+
+// stepSkipped records that step i did not run
+func (r *runReport) stepSkipped(i int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Status = statusSkipped
+}
+
+// addSteps appends the steps of o, if not nil, to r prefixing their names
+func (r *runReport) addSteps(prefix string, o *runReport) {
+	if o == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range o.Steps {
+		s.Name = prefix + s.Name
+		r.Steps = append(r.Steps, s)
+	}
+}
+
+// finish records the outcome of the whole run. Steps that did not
+// finish are marked as signalled when the run was interrupted, or as
+// skipped otherwise
+func (r *runReport) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Duration = time.Since(r.Start).Seconds()
+
+	unfinished := statusSkipped
+
+	switch {
+	case err == nil:
+		r.Status = statusSuccess
+	case errors.Is(err, ErrSignal), errors.Is(err, context.Canceled):
+		r.Status = statusSignalled
+		unfinished = statusSignalled
+	default:
+		r.Status = statusFailed
+	}
+
+	for i := range r.Steps {
+		if r.Steps[i].Status == "" {
+			r.Steps[i].Status = unfinished
+		}
+	}
+}
+
+// save writes the report to path as JUnit XML when the file extension
+// is .xml, or as JSON otherwise
+func (r *runReport) save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var data []byte
+	var err error
+
+	if filepath.Ext(path) == ".xml" {
+		data, err = xml.MarshalIndent(r.junit(), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	} else {
+		data, err = json.MarshalIndent(r, "", "  ")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// json returns the report encoded as JSON
+func (r *runReport) json() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return json.Marshal(r)
+}
+
+// stepStatus maps the error returned by a step to its report status
+func stepStatus(err error) string {
+	if err == nil {
+		return statusSuccess
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return statusTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return statusSignalled
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return statusSignalled
+		}
+	}
+
+	return statusFailed
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junit converts the report into a JUnit test suite with one test case
+// per step. Failed steps are failures, timed out or signalled steps are
+// errors. The tests run by test steps are added as one more test suite
+// per package
+func (r *runReport) junit() junitTestSuites {
+	suite := junitTestSuite{
+		Name:      "goci: " + r.Project,
+		Tests:     len(r.Steps),
+		Time:      r.Duration,
+		Timestamp: r.Start.Format(time.RFC3339),
+	}
+
+	for _, s := range r.Steps {
+		tc := junitTestCase{
+			Name:      s.Name,
+			Classname: "goci",
+			Time:      s.Duration,
+			SystemOut: s.Output,
+		}
+
+		msg := &junitMessage{Message: s.Error, Type: s.Status}
+		for _, f := range s.Findings {
+			msg.Text += f.String() + "\n"
+		}
+
+		switch s.Status {
+		case statusFailed:
+			suite.Failures++
+			tc.Failure = msg
+		case statusTimeout, statusSignalled:
+			suite.Errors++
+			tc.Error = msg
+		case statusSkipped:
+			suite.Skipped++
+			tc.Skipped = msg
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suites := junitTestSuites{Suites: []junitTestSuite{suite}}
+
+	for _, s := range r.Steps {
+		if s.Tests == nil {
+			continue
+		}
+
+		for _, p := range s.Tests.Packages {
+			suites.Suites = append(suites.Suites, junitPackage(s, p))
+		}
+	}
+
+	return suites
+}
+
+// junitPackage converts the tests of package p run by step s into a
+// JUnit test suite
+func junitPackage(s stepReport, p testPackage) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      s.Name + ": " + p.Name,
+		Tests:     len(p.Tests),
+		Failures:  p.Failed,
+		Skipped:   p.Skipped,
+		Time:      p.Elapsed,
+		Timestamp: s.Start.Format(time.RFC3339),
+	}
+
+	for _, t := range p.Tests {
+		tc := junitTestCase{
+			Name:      t.Name,
+			Classname: p.Name,
+			Time:      t.Elapsed,
+		}
+
+		switch t.Status {
+		case testFail:
+			tc.Failure = &junitMessage{Message: "test failed", Type: statusFailed, Text: t.Output}
+		case testSkip:
+			tc.Skipped = &junitMessage{Type: statusSkipped}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if p.Status == testFail && p.Failed == 0 {
+		suite.Errors++
+		suite.Tests++
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      p.Name,
+			Classname: p.Name,
+			Time:      p.Elapsed,
+			Error:     &junitMessage{Message: "package failed", Type: statusFailed, Text: p.Output},
+		})
+	}
+
+	return suite
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunReport(t *testing.T) {
+	expStatus := map[string]string{
+		"go build": statusSuccess,
+		"go test":  statusSuccess,
+		"go fmt":   statusFailed,
+		"git push": statusSkipped,
+	}
+
+	t.Run("json", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "report.json")
+
+		if err := run("./testdata/toolFmtErr", io.Discard, config{jobs: 4, report: report}); err == nil {
+			t.Fatal("expected error. got nil.")
+		}
+
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rep runReport
+		if err := json.Unmarshal(data, &rep); err != nil {
+			t.Fatal(err)
+		}
+
+		if rep.Status != statusFailed {
+			t.Errorf("expected run status %q. got %q.", statusFailed, rep.Status)
+		}
+
+		if len(rep.Steps) != len(expStatus) {
+			t.Fatalf("expected %d steps. got %d.", len(expStatus), len(rep.Steps))
+		}
+
+		for _, s := range rep.Steps {
+			if s.Status != expStatus[s.Name] {
+				t.Errorf("expected step %q status %q. got %q.", s.Name, expStatus[s.Name], s.Status)
+			}
+		}
+
+		fmtStep := rep.Steps[2]
+		if fmtStep.Command != "gofmt -l ." {
+			t.Errorf("expected command %q. got %q.", "gofmt -l .", fmtStep.Command)
+		}
+
+		if fmtStep.ExitCode != 0 || fmtStep.Output != "add.go\n" {
+			t.Errorf("expected exit code 0 and output %q. got %d and %q.", "add.go\n", fmtStep.ExitCode, fmtStep.Output)
+		}
+
+		if rep.Steps[3].ExitCode != -1 {
+			t.Errorf("expected exit code -1 for skipped step. got %d.", rep.Steps[3].ExitCode)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "report.xml")
+
+		if err := run("./testdata/toolFmtErr", io.Discard, config{jobs: 4, report: report}); err == nil {
+			t.Fatal("expected error. got nil.")
+		}
+
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var suites junitTestSuites
+		if err := xml.Unmarshal(data, &suites); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(suites.Suites) != 1 {
+			t.Fatalf("expected 1 test suite. got %d.", len(suites.Suites))
+		}
+
+		s := suites.Suites[0]
+		if s.Tests != 4 || s.Failures != 1 || s.Skipped != 1 || s.Errors != 0 {
+			t.Errorf("expected 4 tests, 1 failure, 1 skipped, 0 errors. got %d, %d, %d, %d.", s.Tests, s.Failures, s.Skipped, s.Errors)
+		}
+
+		if s.Cases[2].Failure == nil {
+			t.Errorf("expected failure for test case %q", s.Cases[2].Name)
+		}
+	})
+}
+
+func TestRunReportTests(t *testing.T) {
+	proj := t.TempDir()
+	copyModule(t, "./testdata/toolTestFail", proj)
+
+	pipeline := "steps:\n  - name: test\n    exe: go\n    args: [test, ./...]\n    kind: testStep\n"
+	if err := os.WriteFile(filepath.Join(proj, ".goci.yaml"), []byte(pipeline), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "report.json")
+
+		if err := run(proj, io.Discard, config{jobs: 1, report: report}); err == nil {
+			t.Fatal("expected error. got nil.")
+		}
+
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rep runReport
+		if err := json.Unmarshal(data, &rep); err != nil {
+			t.Fatal(err)
+		}
+
+		sum := rep.Steps[0].Tests
+		if sum == nil {
+			t.Fatal("expected test summary. got nil.")
+		}
+
+		if sum.Passed != 2 || sum.Failed != 2 || sum.Skipped != 1 {
+			t.Errorf("expected 2 passed, 2 failed, 1 skipped. got %d, %d, %d.", sum.Passed, sum.Failed, sum.Skipped)
+		}
+
+		if len(sum.Slowest) == 0 || sum.Slowest[0].Name != "TestAdd" {
+			t.Errorf("expected TestAdd to be the slowest test. got %v.", sum.Slowest)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "report.xml")
+
+		if err := run(proj, io.Discard, config{jobs: 1, report: report}); err == nil {
+			t.Fatal("expected error. got nil.")
+		}
+
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var suites junitTestSuites
+		if err := xml.Unmarshal(data, &suites); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(suites.Suites) != 2 {
+			t.Fatalf("expected 2 test suites. got %d.", len(suites.Suites))
+		}
+
+		s := suites.Suites[1]
+		if s.Name != "test: testdata/toolTestFail" {
+			t.Errorf("expected suite %q. got %q.", "test: testdata/toolTestFail", s.Name)
+		}
+
+		if s.Tests != 5 || s.Failures != 2 || s.Skipped != 1 {
+			t.Errorf("expected 5 tests, 2 failures, 1 skipped. got %d, %d, %d.", s.Tests, s.Failures, s.Skipped)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limits are the resource limits applied to the commands of a step. A
+// zero value means no limit
+type limits struct {
+	cpu    time.Duration
+	memory uint64
+}
+
+func (l limits) isZero() bool {
+	return l.cpu == 0 && l.memory == 0
+}
+
+// usage is the resources used by the commands of a step: the highest
+// peak resident set size and the total user and system CPU time
+type usage struct {
+	maxRSS     int64
+	userTime   time.Duration
+	systemTime time.Duration
+}
+
+// processUsage returns the resources used by a finished process, or nil
+// when it did not start
+func processUsage(ps *os.ProcessState) *usage {
+	if ps == nil {
+		return nil
+	}
+
+	return &usage{
+		maxRSS:     peakRSS(ps),
+		userTime:   ps.UserTime(),
+		systemTime: ps.SystemTime(),
+	}
+}
+
+// add returns the resources used by the commands of both u and o. Either
+// of them may be nil
+func (u *usage) add(o *usage) *usage {
+	if u == nil {
+		return o
+	}
+	if o == nil {
+		return u
+	}
+
+	return &usage{
+		maxRSS:     max(u.maxRSS, o.maxRSS),
+		userTime:   u.userTime + o.userTime,
+		systemTime: u.systemTime + o.systemTime,
+	}
+}
+
+// parseSize parses a size in bytes with an optional K, M or G suffix,
+// optionally followed by iB or B, all of them powers of 1024
+func parseSize(s string) (uint64, error) {
+	num := strings.TrimSpace(s)
+	mult := uint64(1)
+
+	upper := strings.ToUpper(num)
+	for _, suffix := range []string{"IB", "B"} {
+		if strings.HasSuffix(upper, suffix) {
+			upper = strings.TrimSuffix(upper, suffix)
+			break
+		}
+	}
+
+	if n := len(upper); n > 0 {
+		switch upper[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+
+		if mult > 1 {
+			upper = upper[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseUint(strings.TrimSpace(upper), 10, 64)
+	if err != nil || v == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return v * mult, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// limitsEnv carries the limits a re-executed goci applies to itself
+// before executing the command of a step
+const limitsEnv = "GOCI_EXEC_LIMITS"
+
+func init() {
+	if spec, ok := os.LookupEnv(limitsEnv); ok {
+		err := execLimited(spec, os.Args[1:])
+		fmt.Fprintf(os.Stderr, "goci: failed to execute with resource limits: %v\n", err)
+		os.Exit(127)
+	}
+}
+
+// wrap makes cmd start goci again, which sets the limits on itself and
+// then executes the command, so every process it starts is limited from
+// the beginning. The CPU limit first sends SIGXCPU, then SIGKILL a
+// second later
+func (l limits) wrap(cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		// Reported by Start
+		return nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	secs := uint64((l.cpu + 999_999_999) / 1_000_000_000)
+
+	cmd.Env = append(env[:len(env):len(env)], fmt.Sprintf("%s=%d,%d", limitsEnv, secs, l.memory))
+	cmd.Args = append([]string{self, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+
+	return nil
+}
+
+// execLimited sets the limits described by spec on the current process
+// and executes args[0] with the arguments args[1:]. It only returns on
+// failure
+func execLimited(spec string, args []string) error {
+	var secs, memory uint64
+	if _, err := fmt.Sscanf(spec, "%d,%d", &secs, &memory); err != nil {
+		return fmt.Errorf("invalid limits %q: %w", spec, err)
+	}
+
+	if len(args) < 2 {
+		return fmt.Errorf("missing command")
+	}
+
+	if err := os.Unsetenv(limitsEnv); err != nil {
+		return err
+	}
+
+	path, err := syscall.BytePtrFromString(args[0])
+	if err != nil {
+		return err
+	}
+
+	argv, err := cStrings(args[1:])
+	if err != nil {
+		return err
+	}
+
+	envv, err := cStrings(os.Environ())
+	if err != nil {
+		return err
+	}
+
+	// Nothing may be allocated once the memory limit is set, so the
+	// limits and exec go through raw system calls
+	if secs > 0 {
+		if err := setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: secs, Max: secs + 1}); err != nil {
+			return err
+		}
+	}
+
+	if memory > 0 {
+		if err := setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: memory, Max: memory}); err != nil {
+			return err
+		}
+	}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE, uintptr(unsafe.Pointer(path)),
+		uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envv[0])))
+
+	return errno
+}
+
+func setrlimit(resource int, lim *syscall.Rlimit) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SETRLIMIT, uintptr(resource), uintptr(unsafe.Pointer(lim)), 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// cStrings returns the NUL terminated array of C strings holding ss
+func cStrings(ss []string) ([]*byte, error) {
+	p := make([]*byte, len(ss)+1)
+
+	for i, s := range ss {
+		b, err := syscall.BytePtrFromString(s)
+		if err != nil {
+			return nil, err
+		}
+
+		p[i] = b
+	}
+
+	return p, nil
+}
+
+// peakRSS returns the peak resident set size in bytes of the process
+// and the descendants it waited for
+func peakRSS(ps *os.ProcessState) int64 {
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss * 1024
+	}
+
+	return 0
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// wrap reports an error as resource limits are only supported on Linux
+func (l limits) wrap(cmd *exec.Cmd) error {
+	return errors.New("resource limits are only supported on Linux")
+}
+
+// peakRSS is not reported outside Linux
+func peakRSS(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		size   string
+		exp    uint64
+		expErr bool
+	}{
+		{name: "bytes", size: "512", exp: 512},
+		{name: "kilo", size: "4K", exp: 4 << 10},
+		{name: "mebi", size: "512MiB", exp: 512 << 20},
+		{name: "mega", size: "256MB", exp: 256 << 20},
+		{name: "giga", size: "2G", exp: 2 << 30},
+		{name: "lowercase", size: "64m", exp: 64 << 20},
+		{name: "empty", size: "", expErr: true},
+		{name: "zero", size: "0M", expErr: true},
+		{name: "unit", size: "MiB", expErr: true},
+		{name: "unknownUnit", size: "1T", expErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := parseSize(tc.size)
+
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("expected error. got %d.", n)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if n != tc.exp {
+				t.Errorf("expected %d. got %d.", tc.exp, n)
+			}
+		})
+	}
+}
+
+func TestRunResources(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits only supported on Linux. Skipping test")
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed. Skipping test")
+	}
+
+	var testCases = []struct {
+		name      string
+		pipeline  string
+		budget    time.Duration
+		expErr    error
+		expStatus string
+	}{
+		{name: "usage", pipeline: "steps:\n  - name: loop\n    exe: sh\n    args: [\"-c\", \"i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done\"]\n", expStatus: statusSuccess},
+		{name: "cpuLimit", pipeline: "steps:\n  - name: loop\n    exe: sh\n    args: [\"-c\", \"while :; do :; done\"]\n    limits:\n      cpu: 1s\n", budget: 10 * time.Second, expErr: &stepErr{step: "loop"}, expStatus: statusSignalled},
+		{name: "memoryLimit", pipeline: "steps:\n  - name: ulimit\n    exe: sh\n    args: [\"-c\", \"test \\\"$(sh -c 'ulimit -v')\\\" = 32768\"]\n    limits:\n      memory: 32MiB\n", expStatus: statusSuccess},
+		{name: "pipelineBudget", pipeline: "budget: 200ms\nsteps:\n  - name: sleep\n    exe: sh\n    args: [\"-c\", \"exec sleep 10\"]\n", expErr: ErrBudget, expStatus: statusTimeout},
+		{name: "flagBudget", pipeline: "budget: 1h\nsteps:\n  - name: sleep\n    exe: sh\n    args: [\"-c\", \"exec sleep 10\"]\n", budget: 200 * time.Millisecond, expErr: ErrBudget, expStatus: statusTimeout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proj := t.TempDir()
+
+			if err := os.WriteFile(filepath.Join(proj, ".goci.yaml"), []byte(tc.pipeline), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			report := filepath.Join(t.TempDir(), "report.json")
+
+			err := run(proj, io.Discard, config{jobs: 1, report: report, budget: tc.budget})
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			data, err := os.ReadFile(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rep runReport
+			if err := json.Unmarshal(data, &rep); err != nil {
+				t.Fatal(err)
+			}
+
+			s := rep.Steps[0]
+			if s.Status != tc.expStatus {
+				t.Errorf("expected step status %q. got %q.", tc.expStatus, s.Status)
+			}
+
+			if s.MaxRSS <= 0 {
+				t.Errorf("expected peak RSS to be reported. got %d.", s.MaxRSS)
+			}
+
+			if tc.name == "cpuLimit" && s.UserTime+s.SystemTime < 0.9 {
+				t.Errorf("expected about 1s of CPU time. got %.2fs.", s.UserTime+s.SystemTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	backoffFixed       = "fixed"
+	backoffExponential = "exponential"
+
+	retryOnAny     = "any"
+	retryOnTimeout = "timeout"
+)
+
+// retryStep runs the wrapped step again when it fails, waiting between
+// attempts according to the backoff policy
+type retryStep struct {
+	executer
+	name        string
+	attempts    int
+	delay       time.Duration
+	exponential bool
+	timeoutOnly bool
+	out         io.Writer
+}
+
+func newRetryStep(e executer, name string, attempts int, delay time.Duration, backoff, on string, out io.Writer) retryStep {
+	return retryStep{
+		executer:    e,
+		name:        name,
+		attempts:    attempts,
+		delay:       delay,
+		exponential: backoff == backoffExponential,
+		timeoutOnly: on == retryOnTimeout,
+		out:         out,
+	}
+}
+
+func (s retryStep) execute(ctx context.Context) (stepResult, error) {
+	delay := s.delay
+
+	var res stepResult
+	log := &attemptLog{}
+
+	for attempt := 1; ; attempt++ {
+		r, err := s.executer.execute(ctx)
+		res = res.then(r)
+
+		log.attempts = attempt
+		res.attempts = log
+
+		if err == nil {
+			return res, nil
+		}
+
+		log.errors = append(log.errors, err.Error())
+
+		retry := attempt < s.attempts && ctx.Err() == nil
+		if s.timeoutOnly && !errors.Is(err, context.DeadlineExceeded) {
+			retry = false
+		}
+
+		if retry {
+			fmt.Fprintf(s.out, "%v: attempt %d of %d, retrying in %s\n", err, attempt, s.attempts, delay)
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				retry = false
+			}
+		}
+
+		if !retry {
+			var sErr *stepErr
+			if errors.As(err, &sErr) {
+				sErr.attempts = attempt
+			}
+
+			return res, err
+		}
+
+		if s.exponential {
+			delay *= 2
+		}
+	}
+}
+
+// attemptLog is the number of attempts a retried step took along with
+// the errors of the failed ones
+type attemptLog struct {
+	attempts int
+	errors   []string
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyStep fails the first failures executions with cause
+type flakyStep struct {
+	failures int
+	cause    error
+	runs     *int
+}
+
+func (f flakyStep) commandLine() string {
+	return "flaky"
+}
+
+func (f flakyStep) execute(ctx context.Context) (stepResult, error) {
+	*f.runs++
+	if *f.runs <= f.failures {
+		return stepResult{}, &stepErr{step: "flaky", msg: "failed to execute", cause: f.cause}
+	}
+
+	return stepResult{msg: "Flaky: SUCCESS"}, nil
+}
+
+func TestRetryStep(t *testing.T) {
+	errFlaky := errors.New("connection reset")
+
+	var testCases = []struct {
+		name        string
+		failures    int
+		cause       error
+		attempts    int
+		backoff     string
+		on          string
+		expRuns     int
+		expErr      bool
+		expAttempts int
+		minElapsed  time.Duration
+	}{
+		{name: "successAfterRetry", failures: 2, cause: errFlaky, attempts: 3, backoff: backoffFixed, on: retryOnAny, expRuns: 3, expErr: false, expAttempts: 3, minElapsed: 20 * time.Millisecond},
+		{name: "exhausted", failures: 5, cause: errFlaky, attempts: 2, backoff: backoffFixed, on: retryOnAny, expRuns: 2, expErr: true, expAttempts: 2},
+		{name: "exponential", failures: 3, cause: errFlaky, attempts: 4, backoff: backoffExponential, on: retryOnAny, expRuns: 4, expErr: false, expAttempts: 4, minElapsed: 70 * time.Millisecond},
+		{name: "timeoutOnlyNotTimeout", failures: 1, cause: errFlaky, attempts: 3, backoff: backoffFixed, on: retryOnTimeout, expRuns: 1, expErr: true, expAttempts: 1},
+		{name: "timeoutOnly", failures: 1, cause: context.DeadlineExceeded, attempts: 3, backoff: backoffFixed, on: retryOnTimeout, expRuns: 2, expErr: false, expAttempts: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runs := 0
+			var out bytes.Buffer
+
+			s := newRetryStep(flakyStep{failures: tc.failures, cause: tc.cause, runs: &runs}, "flaky", tc.attempts, 10*time.Millisecond, tc.backoff, tc.on, &out)
+
+			start := time.Now()
+			res, err := s.execute(context.Background())
+			elapsed := time.Since(start)
+
+			if res.attempts == nil || res.attempts.attempts != tc.expAttempts {
+				t.Errorf("expected %d recorded attempts. got %v.", tc.expAttempts, res.attempts)
+			}
+
+			if expErrs := min(tc.failures, runs); res.attempts != nil && len(res.attempts.errors) != expErrs {
+				t.Errorf("expected %d recorded attempt errors. got %q.", expErrs, res.attempts.errors)
+			}
+
+			if runs != tc.expRuns {
+				t.Errorf("expected %d runs. got %d.", tc.expRuns, runs)
+			}
+
+			if lines := strings.Count(out.String(), "\n"); lines != runs-1 {
+				t.Errorf("expected %d retry notices. got %q.", runs-1, out.String())
+			}
+
+			if elapsed < tc.minElapsed {
+				t.Errorf("expected backoff of at least %s. got %s.", tc.minElapsed, elapsed)
+			}
+
+			if !tc.expErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %q", err)
+				}
+
+				if res.msg != "Flaky: SUCCESS" {
+					t.Errorf("expected message %q. got %q.", "Flaky: SUCCESS", res.msg)
+				}
+
+				return
+			}
+
+			var sErr *stepErr
+			if !errors.As(err, &sErr) {
+				t.Fatalf("expected step error. got %q.", err)
+			}
+
+			if sErr.attempts != tc.expAttempts {
+				t.Errorf("expected %d attempts. got %d.", tc.expAttempts, sErr.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryStepReport(t *testing.T) {
+	runs := 0
+	s := newRetryStep(flakyStep{failures: 1, cause: errors.New("connection reset"), runs: &runs}, "flaky", 3, time.Millisecond, backoffFixed, retryOnAny, io.Discard)
+
+	tasks := []task{{name: "flaky", exec: s}}
+	rep := newRunReport("proj", tasks)
+
+	if err := runTasks(context.Background(), tasks, 1, io.Discard, rep); err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+
+	step := rep.Steps[0]
+	if step.Status != statusSuccess || step.Attempts != 2 {
+		t.Errorf("expected status %q after 2 attempts. got %q after %d.", statusSuccess, step.Status, step.Attempts)
+	}
+
+	if len(step.Retries) != 1 || !strings.Contains(step.Retries[0], "connection reset") {
+		t.Errorf("expected the error of the failed attempt. got %q.", step.Retries)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// task is a pipeline step along with the indexes of the steps it needs.
+// A task marked skip does not run but its dependents do. A task with a
+// cache is not run while its inputs match a previous successful run.
+// A task with a condition is skipped when it is not met, and a task
+// listing onFailure steps waits for them and is skipped unless one of
+// them failed. Finally tasks run once all other tasks are over, even
+// when the run failed or was cancelled
+type task struct {
+	name      string
+	needs     []int
+	exec      executer
+	skip      bool
+	cache     *stepCache
+	cond      *condition
+	onFailure []int
+	finally   bool
+}
+
+const (
+	taskPending = iota
+	taskRunning
+	taskDone
+	taskFailed
+	taskSkipped
+)
+
+type taskResult struct {
+	stepResult
+	i       int
+	err     error
+	cached  bool
+	skipped bool
+	fixed   bool
+	diff    string
+}
+
+// runTasks executes the pipeline honoring the dependencies between tasks,
+// running up to jobs tasks at a time. A failed task cancels every task
+// that depends on it, while tasks already running are allowed to finish.
+// Cancelling ctx stops running tasks and skips the ones not started yet,
+// except for finally tasks which run without it.
+// Success messages are written to out in pipeline order and the error
+// returned belongs to the first failed task in pipeline order. The
+// outcome of every task is recorded in rep, if not nil
+func runTasks(ctx context.Context, tasks []task, jobs int, out io.Writer, rep *runReport) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	state := make([]int, len(tasks))
+	msgs := make([]string, len(tasks))
+	errs := make([]error, len(tasks))
+
+	results := make(chan taskResult)
+	running := 0
+	runningMain := 0
+	printed := 0
+
+	var werr error
+
+	for {
+		// Cancel tasks that depend on a failed or cancelled task and,
+		// once the run is cancelled, every other task but finally tasks
+		for changed := true; changed; {
+			changed = false
+
+			for i, t := range tasks {
+				if state[i] != taskPending {
+					continue
+				}
+
+				if !t.finally && (werr != nil || ctx.Err() != nil) {
+					state[i] = taskSkipped
+					rep.stepSkipped(i)
+					changed = true
+					continue
+				}
+
+				for _, n := range t.needs {
+					if state[n] == taskFailed || state[n] == taskSkipped {
+						state[i] = taskSkipped
+						rep.stepSkipped(i)
+						changed = true
+						break
+					}
+				}
+			}
+		}
+
+		// Start the tasks whose dependencies succeeded. Skipping a task
+		// may make others ready, so repeat until nothing changes
+		for changed := true; changed; {
+			changed = false
+
+			for i, t := range tasks {
+				if running >= jobs {
+					break
+				}
+
+				if state[i] != taskPending || !ready(t, state) || !finished(t.onFailure, state) {
+					continue
+				}
+
+				if t.finally {
+					// Finally tasks run regardless of the outcome of
+					// the run, once no other task can start
+					if runningMain > 0 || werr == nil && ctx.Err() == nil && !mainFinished(tasks, state) {
+						continue
+					}
+				} else if werr != nil || ctx.Err() != nil {
+					continue
+				}
+
+				if t.skip || len(t.onFailure) > 0 && !anyFailed(t.onFailure, state) {
+					state[i] = taskDone
+					msgs[i] = fmt.Sprintf("%s: SKIPPED", t.name)
+					rep.stepSkipped(i)
+					changed = true
+					continue
+				}
+
+				state[i] = taskRunning
+				running++
+				rep.stepStarted(i)
+
+				taskCtx := ctx
+				if t.finally {
+					taskCtx = context.WithoutCancel(ctx)
+				} else {
+					runningMain++
+				}
+
+				go func(i int, t task) {
+					results <- runTask(taskCtx, i, t)
+				}(i, t)
+			}
+		}
+
+		// Write messages of finished tasks in pipeline order
+		for printed < len(tasks) && state[printed] >= taskDone {
+			if state[printed] == taskDone && werr == nil {
+				if _, err := fmt.Fprintln(out, msgs[printed]); err != nil {
+					werr = err
+				}
+			}
+			printed++
+		}
+
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		if !tasks[r.i].finally {
+			runningMain--
+		}
+		rep.stepFinished(r.i, r.err)
+		rep.stepUsage(r.i, r.usage)
+		rep.stepTests(r.i, r.tests)
+		rep.stepAttempts(r.i, r.attempts)
+
+		if r.err != nil {
+			state[r.i] = taskFailed
+			errs[r.i] = r.err
+			continue
+		}
+
+		state[r.i] = taskDone
+		msgs[r.i] = r.msg
+
+		switch {
+		case r.skipped:
+			msgs[r.i] = fmt.Sprintf("%s: SKIPPED", tasks[r.i].name)
+			rep.stepSkipped(r.i)
+		case r.cached:
+			msgs[r.i] = annotate(msgs[r.i], "cached")
+			rep.stepCached(r.i)
+		case r.fixed:
+			msgs[r.i] = annotate(msgs[r.i], "fixed")
+			rep.stepFixed(r.i, r.diff)
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return werr
+}
+
+// runTask runs task t, or skips it when its condition is not met or its
+// cache holds a previous successful run with the same inputs. Failing to
+// read the inputs or to write the cache only disables caching for this run
+func runTask(ctx context.Context, i int, t task) taskResult {
+	if t.cond != nil {
+		ok, err := t.cond.met(ctx)
+		if err != nil {
+			return taskResult{i: i, err: &stepErr{
+				step:     t.name,
+				msg:      "failed to evaluate condition",
+				cause:    err,
+				exitCode: exitCode(err),
+			}}
+		}
+
+		if !ok {
+			return taskResult{i: i, skipped: true}
+		}
+	}
+
+	var key string
+
+	if t.cache != nil {
+		if k, err := t.cache.key(); err == nil {
+			key = k
+		}
+
+		if key != "" {
+			if msg, ok := t.cache.lookup(key); ok {
+				return taskResult{stepResult: stepResult{msg: msg}, i: i, cached: true}
+			}
+		}
+	}
+
+	res, err := t.exec.execute(ctx)
+
+	// Fixes change the inputs, so they are not cached
+	var fixed *stepFixed
+	if errors.As(err, &fixed) {
+		res.msg = fixed.msg
+		return taskResult{stepResult: res, i: i, fixed: true, diff: fixed.diff}
+	}
+
+	if err == nil && key != "" {
+		t.cache.store(key, res.msg)
+	}
+
+	return taskResult{stepResult: res, i: i, err: err}
+}
+
+// ready reports whether all dependencies of t completed successfully
+func ready(t task, state []int) bool {
+	for _, n := range t.needs {
+		if state[n] != taskDone {
+			return false
+		}
+	}
+
+	return true
+}
+
+// finished reports whether all the tasks at indexes are over, whatever
+// their outcome
+func finished(indexes []int, state []int) bool {
+	for _, n := range indexes {
+		if state[n] < taskDone {
+			return false
+		}
+	}
+
+	return true
+}
+
+// anyFailed reports whether any of the tasks at indexes failed
+func anyFailed(indexes []int, state []int) bool {
+	for _, n := range indexes {
+		if state[n] == taskFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mainFinished reports whether all tasks other than finally tasks are over
+func mainFinished(tasks []task, state []int) bool {
+	for i, t := range tasks {
+		if !t.finally && state[i] < taskDone {
+			return false
+		}
+	}
+
+	return true
+}
+
+// annotate appends note in parentheses to the first line of msg
+func annotate(msg, note string) string {
+	first, rest, found := strings.Cut(msg, "\n")
+	if !found {
+		return fmt.Sprintf("%s (%s)", msg, note)
+	}
+
+	return fmt.Sprintf("%s (%s)\n%s", first, note, rest)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStep is an executer that sleeps and returns a fixed result while
+// tracking how many fakeSteps run at the same time
+type fakeStep struct {
+	name  string
+	delay time.Duration
+	fail  bool
+	track *tracker
+}
+
+type tracker struct {
+	mu      sync.Mutex
+	current int
+	max     int
+	ran     []string
+}
+
+func (f fakeStep) commandLine() string {
+	return "fake " + f.name
+}
+
+func (f fakeStep) execute(ctx context.Context) (stepResult, error) {
+	f.track.mu.Lock()
+	f.track.current++
+	if f.track.current > f.track.max {
+		f.track.max = f.track.current
+	}
+	f.track.ran = append(f.track.ran, f.name)
+	f.track.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.track.mu.Lock()
+	f.track.current--
+	f.track.mu.Unlock()
+
+	if f.fail {
+		return stepResult{}, &stepErr{step: f.name, msg: "failed to execute"}
+	}
+
+	return stepResult{msg: f.name + ": SUCCESS"}, nil
+}
+
+func TestRunTasks(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		jobs    int
+		delays  []time.Duration
+		fail    []bool
+		needs   [][]int
+		out     string
+		expErr  error
+		expMax  int
+		expRuns int
+	}{
+		{
+			name:    "sequential",
+			jobs:    1,
+			delays:  []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond},
+			fail:    []bool{false, false, false},
+			needs:   [][]int{nil, nil, nil},
+			out:     "s0: SUCCESS\ns1: SUCCESS\ns2: SUCCESS\n",
+			expMax:  1,
+			expRuns: 3,
+		},
+		{
+			name:    "parallelOrderedOutput",
+			jobs:    3,
+			delays:  []time.Duration{300 * time.Millisecond, 200 * time.Millisecond, 100 * time.Millisecond, 10 * time.Millisecond},
+			fail:    []bool{false, false, false, false},
+			needs:   [][]int{nil, nil, nil, {0, 1, 2}},
+			out:     "s0: SUCCESS\ns1: SUCCESS\ns2: SUCCESS\ns3: SUCCESS\n",
+			expMax:  3,
+			expRuns: 4,
+		},
+		{
+			name:    "failureSkipsDependents",
+			jobs:    2,
+			delays:  []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond},
+			fail:    []bool{false, true, false, false},
+			needs:   [][]int{nil, nil, {1}, {2}},
+			out:     "s0: SUCCESS\n",
+			expErr:  &stepErr{step: "s1"},
+			expMax:  2,
+			expRuns: 2,
+		},
+		{
+			name:    "firstErrorInPipelineOrder",
+			jobs:    2,
+			delays:  []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 10 * time.Millisecond},
+			fail:    []bool{true, true, false},
+			needs:   [][]int{nil, nil, {0, 1}},
+			out:     "",
+			expErr:  &stepErr{step: "s0"},
+			expMax:  2,
+			expRuns: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			track := &tracker{}
+			tasks := make([]task, len(tc.delays))
+
+			for i := range tasks {
+				name := "s" + string(rune('0'+i))
+				tasks[i] = task{
+					name:  name,
+					needs: tc.needs[i],
+					exec:  fakeStep{name: name, delay: tc.delays[i], fail: tc.fail[i], track: track},
+				}
+			}
+
+			var out bytes.Buffer
+			err := runTasks(context.Background(), tasks, tc.jobs, &out, nil)
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %q", err)
+			}
+
+			if out.String() != tc.out {
+				t.Errorf("expected output: %q. got %q.", tc.out, out.String())
+			}
+
+			if track.max != tc.expMax {
+				t.Errorf("expected %d concurrent steps. got %d.", tc.expMax, track.max)
+			}
+
+			if len(track.ran) != tc.expRuns {
+				t.Errorf("expected %d steps to run. got %v.", tc.expRuns, track.ran)
+			}
+		})
+	}
+}
+
+func TestRunTasksConditional(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		fail      []bool
+		onFailure [][]int
+		finally   []bool
+		cancelled bool
+		out       string
+		expErr    error
+		expRan    []string
+	}{
+		{
+			name:      "failureTriggersStep",
+			fail:      []bool{true, false, false},
+			onFailure: [][]int{nil, {0}, nil},
+			finally:   []bool{false, false, true},
+			out:       "s1: SUCCESS\ns2: SUCCESS\n",
+			expErr:    &stepErr{step: "s0"},
+			expRan:    []string{"s0", "s1", "s2"},
+		},
+		{
+			name:      "successSkipsFailureStep",
+			fail:      []bool{false, false, false},
+			onFailure: [][]int{nil, {0}, nil},
+			finally:   []bool{false, false, true},
+			out:       "s0: SUCCESS\ns1: SKIPPED\ns2: SUCCESS\n",
+			expRan:    []string{"s0", "s2"},
+		},
+		{
+			name:      "finallyAfterFailure",
+			fail:      []bool{true, false, false},
+			onFailure: [][]int{nil, nil, nil},
+			finally:   []bool{false, true, true},
+			out:       "s1: SUCCESS\ns2: SUCCESS\n",
+			expErr:    &stepErr{step: "s0"},
+			expRan:    []string{"s0", "s1", "s2"},
+		},
+		{
+			name:      "finallyAfterCancel",
+			fail:      []bool{false, false},
+			onFailure: [][]int{nil, nil},
+			finally:   []bool{false, true},
+			cancelled: true,
+			out:       "s1: SUCCESS\n",
+			expErr:    context.Canceled,
+			expRan:    []string{"s1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			track := &tracker{}
+			tasks := make([]task, len(tc.fail))
+
+			for i := range tasks {
+				name := "s" + string(rune('0'+i))
+				tasks[i] = task{
+					name:      name,
+					onFailure: tc.onFailure[i],
+					finally:   tc.finally[i],
+					exec:      fakeStep{name: name, delay: 10 * time.Millisecond, fail: tc.fail[i], track: track},
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tc.cancelled {
+				cancel()
+			}
+
+			var out bytes.Buffer
+			err := runTasks(ctx, tasks, 1, &out, nil)
+
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("expected error: %q. got %q.", tc.expErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %q", err)
+			}
+
+			if out.String() != tc.out {
+				t.Errorf("expected output: %q. got %q.", tc.out, out.String())
+			}
+
+			if !reflect.DeepEqual(track.ran, tc.expRan) {
+				t.Errorf("expected steps %v to run. got %v.", tc.expRan, track.ran)
+			}
+		})
+	}
+}
+
+func TestRunTasksBudget(t *testing.T) {
+	track := &tracker{}
+
+	tasks := []task{
+		{name: "a", exec: fakeStep{name: "a", delay: 300 * time.Millisecond, track: track}},
+		{name: "b", needs: []int{0}, exec: fakeStep{name: "b", track: track}},
+		{name: "c", exec: fakeStep{name: "c", track: track}},
+		{name: "clean", finally: true, exec: fakeStep{name: "clean", track: track}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	rep := newRunReport("proj", tasks)
+
+	var out bytes.Buffer
+	err := runTasks(ctx, tasks, 1, &out, rep)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error: %q. got %q.", context.DeadlineExceeded, err)
+	}
+
+	if exp := "a: SUCCESS\nclean: SUCCESS\n"; out.String() != exp {
+		t.Errorf("expected output: %q. got %q.", exp, out.String())
+	}
+
+	expStatus := []string{statusSuccess, statusSkipped, statusSkipped, statusSuccess}
+	for i, s := range rep.Steps {
+		if s.Status != expStatus[i] {
+			t.Errorf("expected step %q status %q. got %q.", s.Name, expStatus[i], s.Status)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultSecretsFile is the secrets file read, relative to the project
+// directory, when the pipeline does not name one
+const defaultSecretsFile = ".goci.secrets"
+
+// secretMask replaces secret values in output and reports
+const secretMask = "***"
+
+// maxPendingLine is how much of an unfinished line maskWriter holds back
+// before writing it anyway
+const maxPendingLine = 4096
+
+// parseSecrets reads KEY=VALUE lines. Blank lines and lines starting
+// with # are ignored and values may be enclosed in single or double quotes
+func parseSecrets(r io.Reader) (map[string]string, error) {
+	secrets := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		secrets[key] = value
+	}
+
+	return secrets, scanner.Err()
+}
+
+// loadSecrets reads the secrets file at path
+func loadSecrets(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	secrets, err := parseSecrets(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return secrets, nil
+}
+
+// masker replaces secret values with secretMask
+type masker struct {
+	r *strings.Replacer
+}
+
+func newMasker(secrets []string) masker {
+	values := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		if s != "" {
+			values = append(values, s)
+		}
+	}
+
+	if len(values) == 0 {
+		return masker{}
+	}
+
+	// Prefer the longest secret when one contains another
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, secretMask)
+	}
+
+	return masker{r: strings.NewReplacer(pairs...)}
+}
+
+func (m masker) mask(s string) string {
+	if m.r == nil {
+		return s
+	}
+
+	return m.r.Replace(s)
+}
+
+// maskWriter masks secrets in the output written to w. It writes whole
+// lines only, so a secret split across writes is still masked, and holds
+// back the last unfinished line until Flush
+type maskWriter struct {
+	w       io.Writer
+	m       masker
+	pending []byte
+}
+
+func (mw *maskWriter) Write(p []byte) (int, error) {
+	mw.pending = append(mw.pending, p...)
+
+	n := bytes.LastIndexByte(mw.pending, '\n') + 1
+	if len(mw.pending) > maxPendingLine {
+		n = len(mw.pending)
+	}
+
+	if n > 0 {
+		if err := mw.write(n); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes any unfinished line
+func (mw *maskWriter) Flush() error {
+	return mw.write(len(mw.pending))
+}
+
+func (mw *maskWriter) write(n int) error {
+	if n == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(mw.w, mw.m.mask(string(mw.pending[:n])))
+	mw.pending = append(mw.pending[:0], mw.pending[n:]...)
+
+	return err
+}
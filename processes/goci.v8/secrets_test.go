@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSecrets(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		input  string
+		exp    map[string]string
+		expErr bool
+	}{
+		{name: "values", input: "# tokens\nAPI_TOKEN=abc\n\nDB_PASS = \"p=ss word\"\nKEY='x'\n", exp: map[string]string{"API_TOKEN": "abc", "DB_PASS": "p=ss word", "KEY": "x"}},
+		{name: "empty", input: "", exp: map[string]string{}},
+		{name: "missingValue", input: "API_TOKEN\n", expErr: true},
+		{name: "missingKey", input: "=abc\n", expErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			secrets, err := parseSecrets(strings.NewReader(tc.input))
+
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("expected error. got %v.", secrets)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+
+			if !reflect.DeepEqual(secrets, tc.exp) {
+				t.Errorf("expected %v. got %v.", tc.exp, secrets)
+			}
+		})
+	}
+}
+
+func TestMaskWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &maskWriter{w: &out, m: newMasker([]string{"s3cr3t", "s3cr3t-long", ""})}
+
+	// Secrets split across writes are masked too
+	for _, chunk := range []string{"token=s3c", "r3t\nother=s3cr3t-lo", "ng\nlast s3cr", "3t"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if exp := "token=***\nother=***\n"; out.String() != exp {
+		t.Errorf("expected %q before flush. got %q.", exp, out.String())
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "token=***\nother=***\nlast ***"; out.String() != exp {
+		t.Errorf("expected %q. got %q.", exp, out.String())
+	}
+}
+
+func TestRunStepEnv(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed. Skipping test")
+	}
+
+	proj := t.TempDir()
+
+	files := map[string]string{
+		".goci.yaml": `steps:
+  - name: integration
+    exe: sh
+    args: ["-c", "echo token=$API_TOKEN greeting=$GREETING; pwd; exit 1"]
+    env:
+      GREETING: hello
+    secrets: [API_TOKEN]
+    dir: sub
+`,
+		".goci.secrets": "API_TOKEN=s3cr3t\n",
+		"sub/.keep":     "",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(proj, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := filepath.Join(t.TempDir(), "report.json")
+
+	var out bytes.Buffer
+	err := run(proj, &out, config{jobs: 1, verbose: true, report: report})
+
+	var sErr *stepErr
+	if !errors.As(err, &sErr) {
+		t.Fatalf("expected step error. got %q.", err)
+	}
+
+	for _, exp := range []string{"token=*** greeting=hello\n", filepath.Join(proj, "sub") + "\n"} {
+		if !strings.Contains(sErr.output, exp) {
+			t.Errorf("expected output to contain %q. got %q.", exp, sErr.output)
+		}
+	}
+
+	rep, rErr := os.ReadFile(report)
+	if rErr != nil {
+		t.Fatal(rErr)
+	}
+
+	for name, s := range map[string]string{"output": sErr.output, "stream": out.String(), "report": string(rep)} {
+		if strings.Contains(s, "s3cr3t") {
+			t.Errorf("expected secret to be masked in %s. got %q.", name, s)
+		}
+	}
+}
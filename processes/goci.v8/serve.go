@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxLog is the number of bytes of log kept for each run in the history
+const maxLog = 1024 * 1024
+
+// server runs the pipeline of proj when triggered over HTTP, one run at
+// a time, and serves the history of past runs
+type server struct {
+	proj  string
+	cfg   config
+	token string
+	hist  *history
+	log   io.Writer
+
+	// queue holds at most one pending trigger, further triggers are
+	// merged into it
+	queue chan string
+
+	mu      sync.Mutex
+	running string
+}
+
+func newServer(proj string, cfg config, token string, hist *history, log io.Writer) *server {
+	return &server{
+		proj:  proj,
+		cfg:   cfg,
+		token: token,
+		hist:  hist,
+		log:   log,
+		queue: make(chan string, 1),
+	}
+}
+
+// loop runs the pipeline for every trigger until ctx is cancelled
+func (s *server) loop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case trigger := <-s.queue:
+			s.runPipeline(ctx, trigger)
+		}
+	}
+}
+
+// trigger queues a run, reporting false when one is already queued
+func (s *server) trigger(reason string) bool {
+	select {
+	case s.queue <- reason:
+		return true
+	default:
+		return false
+	}
+}
+
+// runPipeline runs the pipeline once, capturing the output of every
+// step, and records the outcome in the history
+func (s *server) runPipeline(ctx context.Context, trigger string) {
+	s.setRunning(trigger)
+	defer s.setRunning("")
+
+	cfg := s.cfg
+	cfg.verbose = true
+
+	log := newOutputBuffer(maxLog)
+	start := time.Now()
+
+	logOut := &syncWriter{w: log}
+
+	rep, notify, err := execPipeline(ctx, s.proj, logOut, cfg)
+	notifyAll(ctx, notify, s.proj, rep, err, logOut)
+
+	rec, hErr := s.hist.add(newRunRecord(trigger, start, rep, err, log.String()))
+	if hErr != nil {
+		fmt.Fprintf(s.log, "Failed to save run: %v\n", hErr)
+		return
+	}
+
+	fmt.Fprintf(s.log, "Run %d (%s): %s\n", rec.ID, trigger, rec.Status)
+}
+
+func (s *server) setRunning(trigger string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = trigger
+}
+
+func (s *server) currentRun() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.running
+}
+
+// routes returns the handler serving the API and HTML pages
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/runs", s.listRuns)
+	mux.HandleFunc("POST /api/runs", s.triggerRun)
+	mux.HandleFunc("GET /api/runs/{id}", s.getRun)
+	mux.HandleFunc("GET /{$}", s.indexPage)
+	mux.HandleFunc("GET /runs/{id}", s.runPage)
+
+	return mux
+}
+
+func (s *server) listRuns(w http.ResponseWriter, r *http.Request) {
+	replyJSON(w, http.StatusOK, s.hist.list())
+}
+
+func (s *server) getRun(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.lookup(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	replyJSON(w, http.StatusOK, rec)
+}
+
+// triggerRun queues a new run. Requests sent by browsers are refused so
+// web pages cannot trigger runs, with or without a token. When a token is
+// configured the request must carry it as a bearer token
+func (s *server) triggerRun(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != "" {
+		http.Error(w, "runs cannot be triggered from a browser", http.StatusForbidden)
+		return
+	}
+
+	if s.token != "" {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte(s.token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	reason := "api"
+	if ref := r.URL.Query().Get("ref"); ref != "" {
+		reason = "push " + ref
+	}
+
+	queued := s.trigger(reason)
+	replyJSON(w, http.StatusAccepted, map[string]bool{"queued": queued})
+}
+
+func (s *server) indexPage(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Project string
+		Running string
+		Runs    []runRecord
+	}{s.proj, s.currentRun(), s.hist.list()}
+
+	renderPage(w, indexTemplate, data)
+}
+
+func (s *server) runPage(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.lookup(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	renderPage(w, runTemplate, rec)
+}
+
+// lookup returns the run named by the id path value of r
+func (s *server) lookup(r *http.Request) (runRecord, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return runRecord{}, false
+	}
+
+	return s.hist.get(id)
+}
+
+func replyJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(v)
+}
+
+func renderPage(w http.ResponseWriter, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var pageFuncs = template.FuncMap{
+	"seconds": func(d float64) string {
+		return (time.Duration(d * float64(time.Second))).Round(time.Millisecond).String()
+	},
+	"time": func(t time.Time) string {
+		return t.Format(time.DateTime)
+	},
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><title>goci: {{.Project}}</title></head>
+<body>
+<h1>goci: {{.Project}}</h1>
+{{if .Running}}<p>Running: {{.Running}}</p>{{end}}
+<table>
+<tr><th>Run</th><th>Trigger</th><th>Start</th><th>Duration</th><th>Status</th></tr>
+{{range .Runs}}<tr><td><a href="/runs/{{.ID}}">{{.ID}}</a></td><td>{{.Trigger}}</td><td>{{time .Start}}</td><td>{{seconds .Duration}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var runTemplate = template.Must(template.New("run").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><title>goci: run {{.ID}}</title></head>
+<body>
+<h1>Run {{.ID}}: {{.Status}}</h1>
+<p>Trigger: {{.Trigger}}, started {{time .Start}}, took {{seconds .Duration}}</p>
+{{if .Error}}<p>Error: {{.Error}}</p>{{end}}
+<table>
+<tr><th>Step</th><th>Command</th><th>Duration</th><th>Status</th></tr>
+{{range .Steps}}<tr><td>{{.Name}}</td><td><code>{{.Command}}</code></td><td>{{seconds .Duration}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+<h2>Log</h2>
+<pre>{{.Log}}</pre>
+<p><a href="/">All runs</a></p>
+</body>
+</html>
+`))
+
+// serve implements the goci serve command: it serves the run history
+// and runs the pipeline of the project on POST /api/runs until it
+// receives SIGINT or SIGTERM
+func serve(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("goci serve", flag.ExitOnError)
+
+	proj := fs.String("p", "", "Project directory")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	histPath := fs.String("history", "", "Run history file (default .goci/history.jsonl in the project)")
+	token := fs.String("token", os.Getenv("GOCI_TOKEN"), "Token required to trigger runs, as an Authorization: Bearer header (default $GOCI_TOKEN)")
+	jobs := fs.Int("j", runtime.NumCPU(), "Maximum number of steps to run concurrently")
+	remote := fs.String("remote", "", "Git remote to push to (default \"origin\")")
+	branch := fs.String("branch", "", "Git branch to push (default current branch)")
+	noPush := fs.Bool("no-push", false, "Run every step except side-effecting ones such as git push")
+	cacheDir := fs.String("cache-dir", "", "Directory for cached step results (default no caching)")
+	isolate := fs.String("isolate", "", "Run every step in a fresh copy of the project: \"copy\" of the files git does not ignore, or \"worktree\" at HEAD")
+
+	fs.Parse(args)
+
+	if *histPath == "" {
+		*histPath = filepath.Join(*proj, ".goci", "history.jsonl")
+	}
+
+	hist, err := loadHistory(*histPath)
+	if err != nil {
+		return err
+	}
+
+	cfg := config{
+		jobs:     *jobs,
+		remote:   *remote,
+		branch:   *branch,
+		noPush:   *noPush,
+		cacheDir: *cacheDir,
+		isolate:  *isolate,
+	}
+
+	if err := checkIsolation(cfg); err != nil {
+		return err
+	}
+
+	out = &syncWriter{w: out}
+	s := newServer(*proj, cfg, *token, hist, out)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.loop(ctx)
+		close(done)
+	}()
+
+	srv := &http.Server{Addr: *addr, Handler: s.routes()}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	fmt.Fprintf(out, "Serving %s on http://%s\n", firstNonEmpty(*proj, "."), *addr)
+	if *token == "" {
+		fmt.Fprintln(out, "Warning: no token set, any local process can trigger runs")
+	}
+
+	select {
+	case err := <-errCh:
+		stop()
+		<-done
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting triggers, then wait for the run in progress
+	// to be cancelled
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = srv.Shutdown(shutdownCtx)
+	<-done
+
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	return err
+}
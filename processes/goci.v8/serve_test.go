@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	histPath := filepath.Join(t.TempDir(), "history.jsonl")
+
+	hist, err := loadHistory(histPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log lockedBuffer
+	s := newServer("./testdata/toolPipeline", config{jobs: 2}, "s3cr3t", hist, &log)
+
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.loop(ctx)
+
+	post := func(auth, origin string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/runs?ref=refs/heads/main", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp
+	}
+
+	get := func(path string) (int, string) {
+		t.Helper()
+
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return resp.StatusCode, string(body)
+	}
+
+	if resp := post("wrong", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a valid token. got %d.", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	if resp := post("s3cr3t", "https://example.com"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d for a browser request. got %d.", http.StatusForbidden, resp.StatusCode)
+	}
+
+	if resp := post("s3cr3t", ""); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d. got %d.", http.StatusAccepted, resp.StatusCode)
+	}
+
+	var runs []runRecord
+	for deadline := time.Now().Add(30 * time.Second); len(runs) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the run")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		_, body := get("/api/runs")
+		if err := json.Unmarshal([]byte(body), &runs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if r := runs[0]; r.ID != 1 || r.Status != statusSuccess || r.Trigger != "push refs/heads/main" || len(r.Steps) != 2 {
+		t.Errorf("unexpected run %+v", r)
+	}
+
+	status, body := get("/api/runs/1")
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d. got %d.", http.StatusOK, status)
+	}
+
+	var rec runRecord
+	if err := json.Unmarshal([]byte(body), &rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(rec.Log, "Go Vet: SUCCESS\n") {
+		t.Errorf("expected log to contain step messages. got %q.", rec.Log)
+	}
+
+	if status, _ := get("/api/runs/2"); status != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown run. got %d.", http.StatusNotFound, status)
+	}
+
+	for path, exp := range map[string]string{"/": `<a href="/runs/1">1</a>`, "/runs/1": "<td>go vet</td>"} {
+		if _, body := get(path); !strings.Contains(body, exp) {
+			t.Errorf("expected page %s to contain %q. got %q.", path, exp, body)
+		}
+	}
+
+	// The history survives restarts
+	reloaded, err := loadHistory(histPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := reloaded.get(1); !ok || got.Log != rec.Log {
+		t.Errorf("expected run 1 to be reloaded. got %+v.", got)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// defaultRemote is the git remote used when none is configured
+const defaultRemote = "origin"
+
+// sideEffectStep guards a step that changes state outside the project,
+// such as git push. It refuses to run when the working tree has
+// uncommitted changes and, when no branch is configured, detects the
+// current branch before creating the guarded step
+type sideEffectStep struct {
+	name    string
+	proj    string
+	args    []string
+	remote  string
+	branch  string
+	newExec func(args []string) executer
+}
+
+func newSideEffectStep(name, proj string, args []string, remote, branch string, newExec func(args []string) executer) sideEffectStep {
+	return sideEffectStep{
+		name:    name,
+		proj:    proj,
+		args:    args,
+		remote:  remote,
+		branch:  branch,
+		newExec: newExec,
+	}
+}
+
+func (s sideEffectStep) commandLine() string {
+	return s.newExec(expandArgs(s.args, s.remote, firstNonEmpty(s.branch, "{branch}"))).commandLine()
+}
+
+func (s sideEffectStep) execute(ctx context.Context) (stepResult, error) {
+	status, err := gitOutput(ctx, s.proj, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return stepResult{}, &stepErr{
+			step:     s.name,
+			msg:      "failed to check working tree",
+			cause:    err,
+			exitCode: exitCode(err),
+		}
+	}
+
+	if status != "" {
+		return stepResult{}, &stepErr{
+			step:     s.name,
+			msg:      "refusing to run",
+			cause:    ErrDirtyTree,
+			output:   status,
+			exitCode: -1,
+		}
+	}
+
+	branch := s.branch
+	if branch == "" {
+		branch, err = currentBranch(ctx, s.proj)
+		if err != nil {
+			return stepResult{}, &stepErr{
+				step:     s.name,
+				msg:      "failed to detect branch",
+				cause:    err,
+				exitCode: -1,
+			}
+		}
+	}
+
+	return s.newExec(expandArgs(s.args, s.remote, branch)).execute(ctx)
+}
+
+// expandArgs replaces the {remote} and {branch} placeholders in args
+func expandArgs(args []string, remote, branch string) []string {
+	r := strings.NewReplacer("{remote}", remote, "{branch}", branch)
+
+	expanded := make([]string, len(args))
+	for i, a := range args {
+		expanded[i] = r.Replace(a)
+	}
+
+	return expanded
+}
+
+// currentBranch returns the branch checked out in proj
+func currentBranch(ctx context.Context, proj string) (string, error) {
+	branch, err := gitOutput(ctx, proj, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	if branch == "HEAD" {
+		return "", errors.New("HEAD is detached")
+	}
+
+	return branch, nil
+}
+
+// gitOutput runs git with args in proj returning its trimmed output
+func gitOutput(ctx context.Context, proj string, args ...string) (string, error) {
+	cmd := command(ctx, "git", args...)
+	cmd.Dir = proj
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
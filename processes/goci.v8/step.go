@@ -1,15 +1,61 @@
 package main
 
 import (
+	"context"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// defaultGracePeriod is how long a cancelled step has to exit after
+// receiving SIGTERM before it is killed
+const defaultGracePeriod = 5 * time.Second
+
+// stepResult is what a step run returns: its success message along with
+// the resources its commands used, the outcome of its tests and the
+// attempts it took, which are kept in the run report even when it fails
+type stepResult struct {
+	msg      string
+	usage    *usage
+	tests    *testSummary
+	attempts *attemptLog
+}
+
+// then returns the result of a run made of r followed by next, using the
+// resources of both and the message, tests and attempts of next
+func (r stepResult) then(next stepResult) stepResult {
+	next.usage = r.usage.add(next.usage)
+	return next
+}
+
 type step struct {
 	name    string
 	exe     string
 	args    []string
 	message string
 	proj    string
+	stream  io.Writer
+
+	// env holds extra KEY=VALUE variables added to the environment,
+	// the values of secrets are masked in the step output
+	env     []string
+	secrets masker
+
+	// limits restrict the resources used by the step commands
+	limits limits
+
+	// isolate, when set, is the isolation mode running every command in
+	// a fresh copy of the project root instead of proj
+	root    string
+	isolate string
+
+	// grace is how long the step commands have to exit after receiving
+	// SIGTERM on cancellation before they are killed
+	grace time.Duration
 }
 
 func newStep(name, exe, message, proj string, args []string) step {
@@ -19,20 +65,144 @@ func newStep(name, exe, message, proj string, args []string) step {
 		message: message,
 		args:    args,
 		proj:    proj,
+		stream:  io.Discard,
+		grace:   defaultGracePeriod,
+	}
+}
+
+// commandLine returns the command executed by the step
+func (s step) commandLine() string {
+	return strings.Join(append([]string{s.exe}, s.args...), " ")
+}
+
+// terminateOnCancel starts cmd in a process group of its own, which
+// receives SIGTERM when the context of cmd is cancelled, and SIGKILL if
+// any of its processes is still running after grace. The returned
+// function, called once cmd.Wait returns, waits for the processes cmd
+// started to exit as well
+func terminateOnCancel(cmd *exec.Cmd, grace time.Duration) func() {
+	newProcessGroup(cmd)
+
+	var deadline time.Time
+
+	cmd.Cancel = func() error {
+		deadline = time.Now().Add(grace)
+		return signalGroup(cmd.Process, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = grace
+
+	return func() {
+		// Set by Cancel before Wait returns
+		if !deadline.IsZero() {
+			waitGroup(cmd.Process, deadline)
+		}
 	}
 }
 
-func (s step) execute() (string, error) {
-	cmd := exec.Command(s.exe, s.args...)
+// run runs cmd in the step directory, or its counterpart in a fresh
+// isolated copy of the project, and environment, within the step
+// resource limits. Its combined output, with secrets masked, is kept in
+// a bounded buffer and sent to the step live output stream. stdout, if
+// not nil, also receives the unmasked standard output. It returns the
+// captured output along with a step error when cmd fails, times out or
+// is cancelled. The resources cmd used are left in cmd.ProcessState
+func (s step) run(ctx context.Context, cmd *exec.Cmd, stdout io.Writer) (string, error) {
 	cmd.Dir = s.proj
+	if s.isolate != "" {
+		dir, cleanup, err := s.isolatedDir(ctx)
+		if err != nil {
+			return "", &stepErr{step: s.name, msg: "failed to isolate", cause: err, exitCode: -1}
+		}
+		defer cleanup()
+
+		cmd.Dir = dir
+	}
+	if len(s.env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, s.env...)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", &stepErr{
-			step:  s.name,
-			msg:   "failed to execute",
-			cause: err,
+	waitGroup := terminateOnCancel(cmd, s.grace)
+
+	output := newOutputBuffer(maxOutput)
+	w := &maskWriter{w: io.MultiWriter(output, s.stream), m: s.secrets}
+
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if stdout != nil {
+		cmd.Stdout = io.MultiWriter(stdout, w)
+	}
+
+	if !s.limits.isZero() {
+		if err := s.limits.wrap(cmd); err != nil {
+			return "", &stepErr{
+				step:     s.name,
+				msg:      "failed to set resource limits",
+				cause:    err,
+				exitCode: -1,
+			}
 		}
 	}
 
-	return s.message, nil
+	err := cmd.Start()
+
+	if err == nil {
+		err = cmd.Wait()
+		waitGroup()
+	}
+	w.Flush()
+
+	if err == nil {
+		return output.String(), nil
+	}
+
+	sErr := &stepErr{
+		step:     s.name,
+		msg:      "failed to execute",
+		cause:    err,
+		output:   output.String(),
+		exitCode: exitCode(err),
+	}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		sErr.msg = "failed time out"
+		sErr.cause = context.DeadlineExceeded
+	case context.Canceled:
+		sErr.msg = "cancelled"
+		sErr.cause = context.Canceled
+	}
+
+	return sErr.output, sErr
+}
+
+// isolatedDir returns the step directory in a fresh copy of the project
+// along with a function removing the copy
+func (s step) isolatedDir(ctx context.Context) (string, func(), error) {
+	rel, err := filepath.Rel(s.root, s.proj)
+	if err != nil {
+		return "", nil, err
+	}
+
+	root, cleanup, err := isolatedDir(ctx, s.isolate, s.root)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filepath.Join(root, rel), cleanup, nil
+}
+
+func (s step) execute(ctx context.Context) (stepResult, error) {
+	cmd := exec.CommandContext(ctx, s.exe, s.args...)
+
+	_, err := s.run(ctx, cmd, nil)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+	if err != nil {
+		return res, err
+	}
+
+	res.msg = s.message
+	return res, nil
 }
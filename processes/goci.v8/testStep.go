@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// defaultSlowest is the number of slowest tests a testStep reports when
+// its definition does not set one
+const defaultSlowest = 5
+
+const (
+	testPass = "pass"
+	testFail = "fail"
+	testSkip = "skip"
+)
+
+// testEvent is a line of the go test -json event stream
+type testEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// testCase is the outcome of a single test. Output is only kept for
+// failed tests
+type testCase struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Elapsed float64 `json:"elapsed"`
+	Output  string  `json:"output,omitempty"`
+}
+
+// testPackage is the outcome of the tests of a package. Output holds the
+// package output, such as build errors, when it failed
+type testPackage struct {
+	Name    string     `json:"name"`
+	Status  string     `json:"status"`
+	Elapsed float64    `json:"elapsed"`
+	Passed  int        `json:"passed"`
+	Failed  int        `json:"failed"`
+	Skipped int        `json:"skipped"`
+	Output  string     `json:"output,omitempty"`
+	Tests   []testCase `json:"tests,omitempty"`
+}
+
+// slowTest identifies one of the slowest top-level tests of a run
+type slowTest struct {
+	Package string  `json:"package"`
+	Name    string  `json:"name"`
+	Elapsed float64 `json:"elapsed"`
+}
+
+// testSummary is the outcome of a go test run, with the counts of every
+// test and subtest across packages
+type testSummary struct {
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+	Skipped  int           `json:"skipped"`
+	Packages []testPackage `json:"packages"`
+	Slowest  []slowTest    `json:"slowest,omitempty"`
+}
+
+func (s *testSummary) String() string {
+	counts := fmt.Sprintf("%d passed", s.Passed)
+	if s.Failed > 0 {
+		counts += fmt.Sprintf(", %d failed", s.Failed)
+	}
+	if s.Skipped > 0 {
+		counts += fmt.Sprintf(", %d skipped", s.Skipped)
+	}
+
+	return fmt.Sprintf("%s in %d packages", counts, len(s.Packages))
+}
+
+// failed reports whether any test or package failed
+func (s *testSummary) failed() bool {
+	for _, p := range s.Packages {
+		if p.Status == testFail {
+			return true
+		}
+	}
+
+	return s.Failed > 0
+}
+
+// failures returns the output of the failed tests, leaving out tests
+// whose failure comes from a failed subtest, and of the packages that
+// failed without a failed test, such as those not building
+func (s *testSummary) failures() string {
+	var b strings.Builder
+
+	for _, p := range s.Packages {
+		if p.Status == testFail && p.Failed == 0 {
+			fmt.Fprintf(&b, "=== FAIL: %s\n%s", p.Name, p.Output)
+		}
+
+		for _, t := range p.Tests {
+			if t.Status != testFail || failedSubtest(p.Tests, t.Name) {
+				continue
+			}
+
+			fmt.Fprintf(&b, "=== FAIL: %s %s (%.2fs)\n%s", p.Name, t.Name, t.Elapsed, t.Output)
+		}
+	}
+
+	return b.String()
+}
+
+func failedSubtest(tests []testCase, name string) bool {
+	for _, t := range tests {
+		if t.Status == testFail && strings.HasPrefix(t.Name, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTestEvents reads a go test -json event stream and summarizes it,
+// listing up to slowest of the slowest top-level tests. Lines that are
+// not events are ignored
+func parseTestEvents(r io.Reader, slowest int) (*testSummary, error) {
+	sum := &testSummary{}
+
+	pkgs := make(map[string]int)
+	outputs := make(map[[2]string]*strings.Builder)
+
+	pkg := func(name string) *testPackage {
+		i, ok := pkgs[name]
+		if !ok {
+			i = len(sum.Packages)
+			pkgs[name] = i
+			sum.Packages = append(sum.Packages, testPackage{Name: name})
+		}
+
+		return &sum.Packages[i]
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var e testEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+
+		if e.Package == "" {
+			// Build output is reported by import path, possibly
+			// followed by the test variant in brackets
+			e.Package, _, _ = strings.Cut(e.ImportPath, " ")
+		}
+
+		if e.Package == "" {
+			continue
+		}
+
+		p := pkg(e.Package)
+		key := [2]string{e.Package, e.Test}
+
+		switch e.Action {
+		case "output", "build-output":
+			out, ok := outputs[key]
+			if !ok {
+				out = &strings.Builder{}
+				outputs[key] = out
+			}
+			out.WriteString(e.Output)
+
+		case testPass, testFail, testSkip:
+			if e.Test == "" {
+				p.Status = e.Action
+				p.Elapsed = e.Elapsed
+				continue
+			}
+
+			t := testCase{Name: e.Test, Status: e.Action, Elapsed: e.Elapsed}
+
+			switch e.Action {
+			case testPass:
+				p.Passed++
+				sum.Passed++
+			case testFail:
+				p.Failed++
+				sum.Failed++
+				if out, ok := outputs[key]; ok {
+					t.Output = out.String()
+				}
+			case testSkip:
+				p.Skipped++
+				sum.Skipped++
+			}
+
+			p.Tests = append(p.Tests, t)
+
+		case "build-fail":
+			p.Status = testFail
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sum.Packages {
+		p := &sum.Packages[i]
+
+		if p.Status == testFail {
+			if out, ok := outputs[[2]string{p.Name, ""}]; ok {
+				p.Output = out.String()
+			}
+		}
+
+		for _, t := range p.Tests {
+			if !strings.Contains(t.Name, "/") && t.Elapsed > 0 {
+				sum.Slowest = append(sum.Slowest, slowTest{Package: p.Name, Name: t.Name, Elapsed: t.Elapsed})
+			}
+		}
+	}
+
+	sort.SliceStable(sum.Slowest, func(i, j int) bool {
+		return sum.Slowest[i].Elapsed > sum.Slowest[j].Elapsed
+	})
+
+	if len(sum.Slowest) > slowest {
+		sum.Slowest = sum.Slowest[:slowest]
+	}
+
+	return sum, nil
+}
+
+// testStep runs go test with -json and reports the outcome of every
+// package and test, along with the slowest tests
+type testStep struct {
+	step
+	slowest int
+}
+
+func newTestStep(name, exe, message, proj string, args []string, slowest int) testStep {
+	s := testStep{}
+
+	hasJSON := false
+	for _, a := range args {
+		if a == "-json" || a == "--json" {
+			hasJSON = true
+		}
+	}
+
+	if !hasJSON {
+		args = append(append([]string{}, args...), "-json")
+	}
+
+	s.step = newStep(name, exe, message, proj, args)
+	s.slowest = slowest
+	return s
+}
+
+func (s testStep) execute(ctx context.Context) (stepResult, error) {
+	cmd := exec.CommandContext(ctx, s.exe, s.args...)
+
+	var out bytes.Buffer
+
+	output, err := s.run(ctx, cmd, &out)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+
+	sum, pErr := parseTestEvents(&out, s.slowest)
+	if pErr != nil {
+		return res, &stepErr{step: s.name, msg: "failed to parse test events", cause: pErr, output: output, exitCode: -1}
+	}
+	sum.mask(s.secrets)
+
+	res.tests = sum
+
+	// Failures not coming from the tests, such as a timeout or a
+	// missing go command, are reported as they are
+	if err != nil && (ctx.Err() != nil || !sum.failed()) {
+		return res, err
+	}
+
+	if sum.failed() {
+		exitCode := -1
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+
+		return res, &stepErr{
+			step:     s.name,
+			msg:      sum.String(),
+			cause:    ErrTests,
+			output:   sum.failures(),
+			exitCode: exitCode,
+		}
+	}
+
+	res.msg = fmt.Sprintf("%s (%s)", s.message, sum)
+	for _, t := range sum.Slowest {
+		res.msg += fmt.Sprintf("\n  slow: %s %s (%.2fs)", t.Package, t.Name, t.Elapsed)
+	}
+
+	return res, nil
+}
+
+// mask replaces the secret values in the output kept by s
+func (s *testSummary) mask(m masker) {
+	for i := range s.Packages {
+		p := &s.Packages[i]
+		p.Output = m.mask(p.Output)
+
+		for j := range p.Tests {
+			p.Tests[j].Output = m.mask(p.Tests[j].Output)
+		}
+	}
+}
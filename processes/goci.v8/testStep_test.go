@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestEvents(t *testing.T) {
+	stream := `{"Action":"start","Package":"example.com/a"}
+{"Action":"run","Package":"example.com/a","Test":"TestPass"}
+{"Action":"output","Package":"example.com/a","Test":"TestPass","Output":"=== RUN   TestPass\n"}
+{"Action":"pass","Package":"example.com/a","Test":"TestPass","Elapsed":0.3}
+{"Action":"output","Package":"example.com/a","Test":"TestFail","Output":"=== RUN   TestFail\n"}
+{"Action":"output","Package":"example.com/a","Test":"TestFail/sub","Output":"    a_test.go:12: expected 1, got 2\n"}
+{"Action":"fail","Package":"example.com/a","Test":"TestFail/sub","Elapsed":0.1}
+{"Action":"fail","Package":"example.com/a","Test":"TestFail","Elapsed":0.5}
+{"Action":"skip","Package":"example.com/a","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"example.com/a","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/a","Elapsed":1.2}
+not an event
+{"ImportPath":"example.com/b [example.com/b.test]","Action":"build-output","Output":"b.go:3:1: syntax error\n"}
+{"ImportPath":"example.com/b [example.com/b.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/b"}
+{"Action":"output","Package":"example.com/b","Output":"FAIL\texample.com/b [build failed]\n"}
+{"Action":"fail","Package":"example.com/b","Elapsed":0}
+`
+
+	exp := &testSummary{
+		Passed:  1,
+		Failed:  2,
+		Skipped: 1,
+		Packages: []testPackage{
+			{
+				Name: "example.com/a", Status: testFail, Elapsed: 1.2, Passed: 1, Failed: 2, Skipped: 1,
+				Output: "FAIL\n",
+				Tests: []testCase{
+					{Name: "TestPass", Status: testPass, Elapsed: 0.3},
+					{Name: "TestFail/sub", Status: testFail, Elapsed: 0.1, Output: "    a_test.go:12: expected 1, got 2\n"},
+					{Name: "TestFail", Status: testFail, Elapsed: 0.5, Output: "=== RUN   TestFail\n"},
+					{Name: "TestSkip", Status: testSkip},
+				},
+			},
+			{
+				Name: "example.com/b", Status: testFail,
+				Output: "b.go:3:1: syntax error\nFAIL\texample.com/b [build failed]\n",
+			},
+		},
+		Slowest: []slowTest{{Package: "example.com/a", Name: "TestFail", Elapsed: 0.5}},
+	}
+
+	sum, err := parseTestEvents(strings.NewReader(stream), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(sum, exp) {
+		t.Errorf("expected summary %+v. got %+v.", exp, sum)
+	}
+
+	if s := sum.String(); s != "1 passed, 2 failed, 1 skipped in 2 packages" {
+		t.Errorf("expected %q. got %q.", "1 passed, 2 failed, 1 skipped in 2 packages", s)
+	}
+
+	expFailures := "=== FAIL: example.com/a TestFail/sub (0.10s)\n    a_test.go:12: expected 1, got 2\n" +
+		"=== FAIL: example.com/b\nb.go:3:1: syntax error\nFAIL\texample.com/b [build failed]\n"
+
+	if f := sum.failures(); f != expFailures {
+		t.Errorf("expected failures %q. got %q.", expFailures, f)
+	}
+}
+
+func TestTestStep(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		proj      string
+		expMsg    string
+		expErr    error
+		expOutput string
+		expSum    string
+	}{
+		{name: "pass", proj: "./testdata/tool", expMsg: "Go Test: SUCCESS (1 passed in 1 packages)", expSum: "1 passed in 1 packages"},
+		{name: "fail", proj: "./testdata/toolTestFail", expErr: ErrTests, expOutput: "=== FAIL: testdata/toolTestFail TestSub/negative", expSum: "2 passed, 2 failed, 1 skipped in 1 packages"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestStep("go test", "go", "Go Test: SUCCESS", tc.proj, []string{"test", "./..."}, defaultSlowest)
+
+			if cmd := s.commandLine(); cmd != "go test ./... -json" {
+				t.Errorf("expected command %q. got %q.", "go test ./... -json", cmd)
+			}
+
+			res, err := s.execute(context.Background())
+
+			if sum := res.tests; sum == nil || sum.String() != tc.expSum {
+				t.Errorf("expected summary %q. got %v.", tc.expSum, sum)
+			}
+
+			if tc.expErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %q", err)
+				}
+
+				if first, _, _ := strings.Cut(res.msg, "\n"); first != tc.expMsg {
+					t.Errorf("expected message %q. got %q.", tc.expMsg, res.msg)
+				}
+				return
+			}
+
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("expected error: %q. got %q.", tc.expErr, err)
+			}
+
+			var sErr *stepErr
+			if !errors.As(err, &sErr) || !strings.HasPrefix(sErr.output, tc.expOutput) {
+				t.Errorf("expected output to start with %q. got %v.", tc.expOutput, err)
+			}
+
+			if sErr != nil && sErr.exitCode != 1 {
+				t.Errorf("expected exit code 1. got %d.", sErr.exitCode)
+			}
+		})
+	}
+}
+
+func TestRunTestStepSecrets(t *testing.T) {
+	proj := t.TempDir()
+
+	files := map[string]string{
+		".goci.yaml": `steps:
+  - name: test
+    exe: go
+    args: [test, ./...]
+    kind: testStep
+    secrets: [API_TOKEN]
+`,
+		".goci.secrets": "API_TOKEN=s3cr3t\n",
+		"go.mod":        "module leak\n\ngo 1.22\n",
+		"leak_test.go": `package leak
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLeak(t *testing.T) {
+	t.Fatal("token", os.Getenv("API_TOKEN"))
+}
+`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(proj, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"report.json", "report.xml"} {
+		t.Run(name, func(t *testing.T) {
+			report := filepath.Join(t.TempDir(), name)
+
+			err := run(proj, io.Discard, config{jobs: 1, report: report})
+			if !errors.Is(err, ErrTests) {
+				t.Fatalf("expected error: %q. got %q.", ErrTests, err)
+			}
+
+			data, err := os.ReadFile(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(data), "token ***") {
+				t.Errorf("expected the masked test output in the report. got %q.", data)
+			}
+
+			if strings.Contains(string(data), "s3cr3t") {
+				t.Errorf("expected secret to be masked in the report. got %q.", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package add
+
+import (
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	a := 2
+	b := 3
+
+	exp := 5
+
+	res := add(a, b)
+
+	if exp != res {
+		t.Errorf("expected %d, got %d", exp, res)
+	}
+}
@@ -0,0 +1,3 @@
+module testdata/toolCover
+
+go 1.23.4
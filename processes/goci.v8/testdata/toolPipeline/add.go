@@ -0,0 +1,5 @@
+package add
+
+func add(a, b int) int {
+	return a + b
+}
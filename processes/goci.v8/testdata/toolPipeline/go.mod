@@ -0,0 +1,3 @@
+module testdata/toolPipeline
+
+go 1.23.4
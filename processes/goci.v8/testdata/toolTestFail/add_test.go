@@ -0,0 +1,37 @@
+package add
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdd(t *testing.T) {
+	time.Sleep(50 * time.Millisecond)
+
+	if res := add(2, 3); res != 5 {
+		t.Errorf("expected %d, got %d", 5, res)
+	}
+}
+
+func TestSub(t *testing.T) {
+	var testCases = []struct {
+		name string
+		a, b int
+		exp  int
+	}{
+		{name: "positive", a: 3, b: 2, exp: 1},
+		{name: "negative", a: 2, b: 3, exp: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := sub(tc.a, tc.b); res != tc.exp {
+				t.Errorf("expected %d, got %d", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestMul(t *testing.T) {
+	t.Skip("mul not implemented")
+}
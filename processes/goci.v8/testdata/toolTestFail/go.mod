@@ -0,0 +1,3 @@
+module testdata/toolTestFail
+
+go 1.23.4
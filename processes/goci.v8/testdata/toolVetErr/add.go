@@ -0,0 +1,8 @@
+package add
+
+import "fmt"
+
+func add(a, b int) int {
+	fmt.Printf("adding %d and %d\n", a)
+	return a + b
+}
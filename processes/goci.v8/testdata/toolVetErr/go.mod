@@ -0,0 +1,3 @@
+module testdata/toolVetErr
+
+go 1.23.4
@@ -25,30 +25,19 @@ func newTimeoutStep(name, exe, message, proj string, args []string, timeout time
 
 var command = exec.CommandContext
 
-func (s timeoutStep) execute() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+func (s timeoutStep) execute(ctx context.Context) (stepResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 
 	defer cancel()
 
 	cmd := command(ctx, s.exe, s.args...)
 
-	cmd.Dir = s.proj
-
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return "", &stepErr{
-				step:  s.name,
-				msg:   "failed time out",
-				cause: context.DeadlineExceeded,
-			}
-		}
-
-		return "", &stepErr{
-			step:  s.message,
-			msg:   "failed to execute",
-			cause: err,
-		}
+	_, err := s.run(ctx, cmd, nil)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+	if err != nil {
+		return res, err
 	}
 
-	return s.message, nil
+	res.msg = s.message
+	return res, nil
 }
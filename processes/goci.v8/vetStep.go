@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// finding is a single diagnostic reported by a vet step
+type finding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f finding) String() string {
+	if f.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", f.File, f.Line, f.Column, f.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Message)
+}
+
+// diagnosticRe matches the file:line[:column]: message diagnostics
+// printed by go vet and by linters following the same convention
+var diagnosticRe = regexp.MustCompile(`^(?:vet: )?(\S+\.go):(\d+):(?:(\d+):)? (.+)$`)
+
+// vetStep runs go vet, or a linter with the same output format, and
+// reports its diagnostics as structured findings
+type vetStep struct {
+	step
+}
+
+func newVetStep(name, exe, message, proj string, args []string) vetStep {
+	s := vetStep{}
+	s.step = newStep(name, exe, message, proj, args)
+	return s
+}
+
+func (s vetStep) execute(ctx context.Context) (stepResult, error) {
+	cmd := exec.CommandContext(ctx, s.exe, s.args...)
+
+	output, err := s.run(ctx, cmd, nil)
+	res := stepResult{usage: processUsage(cmd.ProcessState)}
+	if ctx.Err() != nil {
+		return res, err
+	}
+
+	findings := parseFindings(output)
+
+	if len(findings) > 0 {
+		return res, &stepErr{
+			step:     s.name,
+			msg:      fmt.Sprintf("%d findings", len(findings)),
+			cause:    ErrFindings,
+			output:   output,
+			exitCode: cmd.ProcessState.ExitCode(),
+			findings: findings,
+		}
+	}
+
+	if err != nil {
+		return res, err
+	}
+
+	res.msg = s.message
+	return res, nil
+}
+
+// parseFindings extracts the diagnostics from the output of a vet step.
+// Lines that are not diagnostics, such as package headers, are ignored
+func parseFindings(output string) []finding {
+	var findings []finding
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		m := diagnosticRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		f := finding{File: m[1], Message: m[4]}
+		f.Line, _ = strconv.Atoi(m[2])
+		f.Column, _ = strconv.Atoi(m[3])
+
+		findings = append(findings, f)
+	}
+
+	return findings
+}
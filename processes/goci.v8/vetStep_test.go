@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFindings(t *testing.T) {
+	output := "# example.com/pkg\n" +
+		"./add.go:6:28: fmt.Printf format %d reads arg #2, but call has 1 arg\n" +
+		"vet: cmd/main.go:12: unreachable code\n" +
+		"exit status 1\n"
+
+	exp := []finding{
+		{File: "./add.go", Line: 6, Column: 28, Message: "fmt.Printf format %d reads arg #2, but call has 1 arg"},
+		{File: "cmd/main.go", Line: 12, Message: "unreachable code"},
+	}
+
+	if got := parseFindings(output); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected findings %v. got %v.", exp, got)
+	}
+}
+
+func TestVetStep(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		proj        string
+		expErr      error
+		expFindings int
+	}{
+		{name: "clean", proj: "./testdata/tool"},
+		{name: "findings", proj: "./testdata/toolVetErr", expErr: ErrFindings, expFindings: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newVetStep("go vet", "go", "Go Vet: SUCCESS", tc.proj, []string{"vet", "./..."})
+
+			res, err := s.execute(context.Background())
+
+			if tc.expErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %q", err)
+				}
+
+				if res.msg != "Go Vet: SUCCESS" {
+					t.Errorf("expected message %q. got %q.", "Go Vet: SUCCESS", res.msg)
+				}
+				return
+			}
+
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("expected error: %q. got %q.", tc.expErr, err)
+			}
+
+			var sErr *stepErr
+			if !errors.As(err, &sErr) || len(sErr.findings) != tc.expFindings {
+				t.Fatalf("expected %d findings. got %v.", tc.expFindings, err)
+			}
+
+			if f := sErr.findings[0]; f.Line != 6 || f.Column != 28 {
+				t.Errorf("expected finding at 6:28. got %s.", f)
+			}
+		})
+	}
+}
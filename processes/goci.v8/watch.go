@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often watch mode checks the project for changes
+var pollInterval = 250 * time.Millisecond
+
+// fileStamp identifies a version of a watched file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot returns the stamps of the files watched in proj: Go sources,
+// go.mod, go.sum, pipeline definitions and anything under testdata
+func snapshot(proj string) (map[string]fileStamp, error) {
+	files := make(map[string]fileStamp)
+
+	err := filepath.WalkDir(proj, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != proj && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !watched(proj, path) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		files[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+
+	return files, err
+}
+
+// watched reports whether a change to path should rerun the pipeline
+func watched(proj, path string) bool {
+	name := filepath.Base(path)
+
+	switch {
+	case filepath.Ext(name) == ".go", name == "go.mod", name == "go.sum":
+		return true
+	}
+
+	for _, f := range pipelineFiles {
+		if name == f {
+			return true
+		}
+	}
+
+	rel, err := filepath.Rel(proj, path)
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if dir == "testdata" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// changed reports whether two snapshots differ
+func changed(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return true
+	}
+
+	for path, s := range a {
+		if t, ok := b[path]; !ok || !s.modTime.Equal(t.modTime) || s.size != t.size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch runs the pipeline, skipping side-effecting steps, every time the
+// project files change. Changes are debounced and a run in progress is
+// cancelled when a new one is due. It returns when ctx is cancelled
+func watch(ctx context.Context, proj string, out io.Writer, cfg config) error {
+	cfg.noPush = true
+
+	prev, err := snapshot(proj)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for n := 1; ; n++ {
+		runCtx, cancelRun := context.WithCancel(ctx)
+		result := make(chan error, 1)
+		start := time.Now()
+
+		go func() {
+			result <- runOnce(runCtx, proj, out, cfg)
+		}()
+
+		running := true
+		var lastChange time.Time
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				cancelRun()
+				if running {
+					<-result
+				}
+				return ctx.Err()
+
+			case err := <-result:
+				running = false
+				printSummary(out, n, time.Since(start), err, cfg.verbose)
+
+			case <-ticker.C:
+				cur, err := snapshot(proj)
+				if err != nil {
+					continue
+				}
+
+				if changed(prev, cur) {
+					prev = cur
+					lastChange = time.Now()
+					continue
+				}
+
+				if lastChange.IsZero() || time.Since(lastChange) < cfg.debounce {
+					continue
+				}
+
+				if running {
+					cancelRun()
+					printSummary(out, n, time.Since(start), <-result, cfg.verbose)
+				}
+
+				break wait
+			}
+		}
+
+		cancelRun()
+	}
+}
+
+// printSummary writes a one line outcome of watch run n, followed by
+// the output of the failed step if any
+func printSummary(out io.Writer, n int, d time.Duration, err error, verbose bool) {
+	d = d.Round(time.Millisecond)
+
+	switch {
+	case err == nil:
+		fmt.Fprintf(out, "Run %d: PASS (%s)\n", n, d)
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintf(out, "Run %d: CANCELLED (%s)\n", n, d)
+	default:
+		fmt.Fprintf(out, "Run %d: FAIL (%s): ", n, d)
+		printErr(out, err, verbose)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while watch writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 20 * time.Millisecond
+
+	proj := t.TempDir()
+	src := filepath.Join(proj, "add.go")
+
+	files := map[string]string{
+		".goci.yaml": "steps:\n  - name: go fmt\n    exe: gofmt\n    args: [-l, .]\n    message: \"Gofmt: SUCCESS\"\n    kind: exceptionStep\n",
+		"add.go":     "package add\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n",
+		"notes.txt":  "not watched\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(proj, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	out := &lockedBuffer{}
+
+	go func() {
+		errCh <- watch(ctx, proj, out, config{jobs: 1, debounce: 50 * time.Millisecond})
+	}()
+
+	waitFor := func(s string) {
+		t.Helper()
+
+		deadline := time.Now().Add(10 * time.Second)
+		for !strings.Contains(out.String(), s) {
+			if time.Now().After(deadline) {
+				cancel()
+				t.Fatalf("expected output to contain %q. got %q.", s, out.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor("Run 1: PASS")
+
+	// Files that are not watched do not trigger a run
+	if err := os.WriteFile(filepath.Join(proj, "notes.txt"), []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if strings.Contains(out.String(), "Run 2") {
+		t.Fatalf("expected no run for unwatched file. got %q.", out.String())
+	}
+
+	if err := os.WriteFile(src, []byte("package add\nfunc add(a, b int) int {\nreturn a + b\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor("Run 2: FAIL")
+
+	if err := os.WriteFile(src, []byte(files["add.go"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor("Run 3: PASS")
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Errorf("expected error: %q. got %q.", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not return after cancel")
+	}
+}